@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/database"
+)
+
+// judge is a judge node: it judges submissions, custom runs, hacks and admin tool jobs for the API servers that
+// list it in JUDGE_NODES, so docker and the judging load stay off the API box.
+// It reads problems from the same database as the API server.
+func main() {
+	if err := database.LoadEnv(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	db := database.Databse{}
+	if err := database.ConectToDb(&db); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Pick the judge sandbox (JUDGE_SANDBOX=docker|local)
+	sandbox, err := cppruner.DefaultSandbox()
+	if err != nil {
+		log.Fatalf("Failed to set up the judge sandbox: %v", err)
+	}
+
+	addr, token := cppruner.JudgeNodeConfigFromEnv()
+	if token == "" {
+		log.Printf("Warning: JUDGE_NODE_TOKEN is not set, anyone who can reach %s can run code here", addr)
+	}
+
+	// JUDGE_WORKERS is the number of jobs judged at once, like the API server's own queue
+	server := cppruner.NewJudgeServer(cppruner.NewLocalJudge(sandbox, &db), token, cppruner.QueueWorkersFromEnv())
+
+	fmt.Printf("Judge node running on %s\n", addr)
+	log.Fatal(http.ListenAndServe(addr, server.Handler()))
+}
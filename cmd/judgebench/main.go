@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// judgebench judges the same submission n times and prints the throughput.
+// Run it once with JUDGE_POOL_SIZE=0 (one docker run per test case) and once
+// with the warm sandbox pool enabled to compare both paths.
+func main() {
+	problemID := flag.Uint("problem", 1, "problem id to judge against")
+	language := flag.String("lang", cppruner.DefaultLanguage, "submission language")
+	codePath := flag.String("code", "", "file containing the solution (function body only)")
+	runs := flag.Int("n", 10, "number of submissions to judge")
+	flag.Parse()
+
+	if *codePath == "" {
+		log.Fatal("-code is required")
+	}
+	code, err := os.ReadFile(*codePath)
+	if err != nil {
+		log.Fatalf("Failed to read code: %v", err)
+	}
+
+	if err := database.LoadEnv(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	db := database.Databse{}
+	if err := database.ConectToDb(&db); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	var problem modles.ProblemPropaty
+	if err := db.Db.Preload("TestCases").Where("id = ?", *problemID).First(&problem).Error; err != nil {
+		log.Fatalf("Failed to load problem %d: %v", *problemID, err)
+	}
+	testCases := cppruner.HiddenTestCases(&problem)
+
+	sandbox, err := cppruner.DefaultSandbox()
+	if err != nil {
+		log.Fatalf("Failed to set up the judge sandbox: %v", err)
+	}
+
+	mode := "warm pool"
+	if os.Getenv("JUDGE_SANDBOX") == cppruner.SandboxLocal {
+		mode = "local sandbox"
+	} else if cppruner.DefaultPool() == nil {
+		mode = "docker run per test"
+	}
+
+	var total time.Duration
+	for i := 0; i < *runs; i++ {
+		started := time.Now()
+		result, err := cppruner.JudgeCode(sandbox, 0, problem.ID, *language, string(code), testCases, cppruner.RunAll, &db)
+		elapsed := time.Since(started)
+		total += elapsed
+		if err != nil {
+			log.Fatalf("Judge failed: %v", err)
+		}
+		fmt.Printf("run %d: %s %d/%d in %v\n", i+1, result.Verdict, result.Passed, result.Total, elapsed)
+	}
+
+	avg := total / time.Duration(*runs)
+	fmt.Printf("\nmode: %s\n", mode)
+	fmt.Printf("test cases per submission: %d\n", len(testCases))
+	fmt.Printf("average per submission: %v\n", avg)
+	fmt.Printf("throughput: %.2f submissions/min\n", float64(*runs)/total.Minutes())
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/handlers"
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
 	"github.com/iAmImran007/Code_War/pkg/database"
 	"github.com/iAmImran007/Code_War/pkg/routes"
 )
@@ -25,8 +26,22 @@ func main() {
 		log.Printf("Error inserting dummy problem: %v", err)
 	}
 
+	// Pick the judge sandbox (JUDGE_SANDBOX=docker|local)
+	sandbox, err := cppruner.DefaultSandbox()
+	if err != nil {
+		log.Fatalf("Failed to set up the judge sandbox: %v", err)
+	}
+
 	// Initialize router with all routes
-	router := routes.NewRouter(&db)
+	router := routes.NewRouter(&db, sandbox)
+
+	// Regenerate expected outputs of problems whose reference solution or limits changed,
+	// on the judge nodes like everything else that runs code
+	go func() {
+		if err := cppruner.RevalidateProblems(router.Judge, &db); err != nil {
+			log.Printf("Error revalidating problems: %v", err)
+		}
+	}()
 
 	//Configure CORS
 	corsOpts := handlers.CORS(
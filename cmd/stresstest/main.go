@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/database"
+)
+
+// stresstest runs a candidate solution against a problem's reference solution on inputs
+// from the problem's generator and prints the first counter-example. With -promote the
+// counter-example is added to the problem's test cases right away.
+func main() {
+	problemID := flag.Uint("problem", 1, "problem id to stress test")
+	language := flag.String("lang", cppruner.DefaultLanguage, "candidate language")
+	codePath := flag.String("code", "", "file containing the candidate solution (function body only)")
+	runs := flag.Int("n", 100, "number of generated inputs")
+	seed := flag.Int64("seed", time.Now().UnixNano()%1000000000, "first generator seed")
+	promote := flag.Bool("promote", false, "add the counter-example to the problem's test cases")
+	group := flag.Int("group", 0, "subtask group of a promoted test case")
+	flag.Parse()
+
+	if *codePath == "" {
+		log.Fatal("-code is required")
+	}
+	code, err := os.ReadFile(*codePath)
+	if err != nil {
+		log.Fatalf("Failed to read code: %v", err)
+	}
+
+	if err := database.LoadEnv(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	db := database.Databse{}
+	if err := database.ConectToDb(&db); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	sandbox, err := cppruner.DefaultSandbox()
+	if err != nil {
+		log.Fatalf("Failed to set up the judge sandbox: %v", err)
+	}
+
+	// On the judge nodes in JUDGE_NODES when set, like the admin endpoint
+	judge := cppruner.DefaultJudge(sandbox, &db)
+	report, err := cppruner.StressTestOn(judge, uint(*problemID), *language, string(code), *runs, *seed)
+	if err != nil {
+		log.Fatalf("Stress test failed: %v", err)
+	}
+
+	if report.CounterExample == nil {
+		fmt.Printf("\nno counter-example in %d runs (seeds %d..%d)\n", report.Runs, *seed, *seed+int64(report.Runs)-1)
+		return
+	}
+
+	ce := report.CounterExample
+	fmt.Printf("\ncounter-example after %d runs, seed %d: %s\n", report.Runs, ce.Seed, ce.Verdict)
+	fmt.Printf("--- input\n%s\n--- expected\n%s\n", ce.Input, ce.ExpectedOutput)
+	if ce.Diff != nil {
+		fmt.Printf("--- diff\n%s", ce.Diff.Diff)
+	}
+	if ce.CheckerMessage != "" {
+		fmt.Printf("checker: %s\n", ce.CheckerMessage)
+	}
+
+	stored, err := cppruner.RecordCounterExample(&db, report)
+	if err != nil {
+		log.Fatalf("Failed to record counter-example: %v", err)
+	}
+	fmt.Printf("stored as counter-example %d\n", stored.ID)
+
+	if *promote {
+		testCase, err := database.PromoteCounterExample(&db, stored.ID, *group)
+		if err != nil {
+			log.Fatalf("Failed to promote counter-example: %v", err)
+		}
+		fmt.Printf("added as test case %d of problem %d\n", testCase.ID, testCase.ProblemID)
+	}
+}
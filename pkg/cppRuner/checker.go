@@ -0,0 +1,183 @@
+package cppruner
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// Built-in comparators a problem can pick in ProblemPropaty.Checker
+const (
+	CheckerExact           = "exact"
+	CheckerTokens          = "tokens"
+	CheckerLines           = "lines"
+	CheckerCaseInsensitive = "case_insensitive"
+	CheckerFloat           = "float"
+	// CheckerCustom runs the problem's own testlib-style checker program
+	CheckerCustom = "custom"
+	// checkerNone accepts any output as VerdictOK, custom runs use it for inputs without an answer
+	checkerNone = "none"
+)
+
+const defaultFloatTolerance = 1e-6
+
+// checkerImage builds custom checkers and interactors, they are linked statically so they run in any toolchain image
+const checkerImage = "gcc:latest"
+
+// checkFunc decides whether output is a correct answer for input
+type checkFunc func(input, expected, output string) (Verdict, string)
+
+// checkerSpec is how a problem wants its answers checked
+type checkerSpec struct {
+	name      string
+	tolerance float64
+	source    string
+}
+
+func checkerSpecFor(problem *modles.ProblemPropaty) (checkerSpec, error) {
+	spec := checkerSpec{
+		name:      strings.ToLower(strings.TrimSpace(problem.Checker)),
+		tolerance: problem.FloatTolerance,
+		source:    problem.CheckerSource,
+	}
+	if spec.name == "" {
+		spec.name = CheckerExact
+	}
+	if spec.tolerance <= 0 {
+		spec.tolerance = defaultFloatTolerance
+	}
+
+	if spec.name == CheckerCustom {
+		if strings.TrimSpace(spec.source) == "" {
+			return spec, fmt.Errorf("problem %d uses a custom checker but has no checker source", problem.ID)
+		}
+		return spec, nil
+	}
+	if _, err := builtinChecker(spec); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// builtinChecker returns the in-process comparator named by spec
+func builtinChecker(spec checkerSpec) (checkFunc, error) {
+	switch spec.name {
+	case CheckerExact:
+		return compareExact, nil
+	case CheckerTokens:
+		return func(_, expected, output string) (Verdict, string) {
+			return compareTokens(expected, output, func(a, b string) bool { return a == b })
+		}, nil
+	case CheckerCaseInsensitive:
+		return func(_, expected, output string) (Verdict, string) {
+			return compareTokens(expected, output, strings.EqualFold)
+		}, nil
+	case CheckerFloat:
+		return func(_, expected, output string) (Verdict, string) {
+			return compareTokens(expected, output, func(a, b string) bool { return floatsEqual(a, b, spec.tolerance) })
+		}, nil
+	case CheckerLines:
+		return compareLines, nil
+	case checkerNone:
+		return func(_, _, _ string) (Verdict, string) { return VerdictOK, "" }, nil
+	}
+	return nil, fmt.Errorf("unknown checker: %s", spec.name)
+}
+
+// compareExact is the original behaviour: the whole output must match after trimming
+func compareExact(_, expected, output string) (Verdict, string) {
+	if strings.TrimSpace(output) == strings.TrimSpace(expected) {
+		return VerdictAccepted, ""
+	}
+	return VerdictWrongAnswer, "output differs from the expected answer"
+}
+
+func compareTokens(expected, output string, equal func(a, b string) bool) (Verdict, string) {
+	want := strings.Fields(expected)
+	got := strings.Fields(output)
+
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if !equal(want[i], got[i]) {
+			return VerdictWrongAnswer, fmt.Sprintf("token %d differs", i+1)
+		}
+	}
+	if len(want) != len(got) {
+		return VerdictWrongAnswer, fmt.Sprintf("expected %d tokens, found %d", len(want), len(got))
+	}
+	return VerdictAccepted, ""
+}
+
+// compareLines compares line by line ignoring trailing spaces and trailing blank lines
+func compareLines(_, expected, output string) (Verdict, string) {
+	want := normalizedLines(expected)
+	got := normalizedLines(output)
+
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if want[i] != got[i] {
+			return VerdictWrongAnswer, fmt.Sprintf("line %d differs", i+1)
+		}
+	}
+	if len(want) != len(got) {
+		return VerdictWrongAnswer, fmt.Sprintf("expected %d lines, found %d", len(want), len(got))
+	}
+	return VerdictAccepted, ""
+}
+
+func normalizedLines(s string) []string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// floatsEqual accepts a token within absolute or relative tolerance, non-numbers must match exactly
+func floatsEqual(want, got string, tolerance float64) bool {
+	a, errA := strconv.ParseFloat(want, 64)
+	b, errB := strconv.ParseFloat(got, 64)
+	if errA != nil || errB != nil {
+		return want == got
+	}
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+
+	diff := math.Abs(a - b)
+	return diff <= tolerance || diff <= tolerance*math.Abs(a)
+}
+
+// checkerVerdict maps testlib exit codes: 0 ok, 1 wrong answer, 2 presentation error,
+// anything else (3 is testlib's "fail") means the checker itself is broken
+func checkerVerdict(exitCode int, message string) (Verdict, string) {
+	message = truncate(strings.TrimSpace(message), maxStderrBytes)
+	switch exitCode {
+	case 0:
+		return VerdictAccepted, message
+	case 1, 2:
+		return VerdictWrongAnswer, message
+	}
+	fmt.Printf("Checker failed with exit code %d: %s\n", exitCode, message)
+	return VerdictJudgeError, message
+}
+
+// checkerCommand runs the compiled checker the testlib way: input, contestant output, answer.
+// The checker has a sandbox of its own, both outputs arrive on stdin (the first outputBytes
+// are the contestant's) and go to TMPDIR since the work directory is read-only there.
+func checkerCommand(outputBytes int) string {
+	return fmt.Sprintf(`d="${TMPDIR:-/tmp}"; head -c %d > "$d/output.txt" && cat > "$d/expected.txt" && ./checker %s "$d/output.txt" "$d/expected.txt"; code=$?; rm -f "$d/output.txt" "$d/expected.txt"; exit $code`, outputBytes, inputFile)
+}
+
+// checkerCompileCommand builds checker.cpp into ./checker
+var checkerCompileCommand = helperCompileCommand("checker")
+
+// helperCompileCommand builds a problem setter's <name>.cpp into ./<name>,
+// linked statically so it runs inside any toolchain image
+func helperCompileCommand(name string) []string {
+	return []string{"g++", "-O2", "-static", "-std=c++17", "-o", name, name + ".cpp"}
+}
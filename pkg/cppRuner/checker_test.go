@@ -0,0 +1,137 @@
+package cppruner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+func TestBuiltinCheckers(t *testing.T) {
+	tests := []struct {
+		checker  string
+		expected string
+		output   string
+		want     Verdict
+	}{
+		{CheckerExact, "3\n", "3", VerdictAccepted},
+		{CheckerExact, "1 2\n", "1  2\n", VerdictWrongAnswer},
+		{CheckerTokens, "1 2\n", "1\n  2 \n", VerdictAccepted},
+		{CheckerTokens, "1 2\n", "1 2 3\n", VerdictWrongAnswer},
+		{CheckerLines, "a b\nc\n", "a b  \nc\n\n\n", VerdictAccepted},
+		{CheckerLines, "a b\nc\n", "a  b\nc\n", VerdictWrongAnswer},
+		{CheckerCaseInsensitive, "YES\n", "yes\n", VerdictAccepted},
+		{CheckerCaseInsensitive, "YES\n", "no\n", VerdictWrongAnswer},
+		{CheckerFloat, "0.333333\n", "0.3333335\n", VerdictAccepted},
+		{CheckerFloat, "0.333333\n", "0.34\n", VerdictWrongAnswer},
+		{CheckerFloat, "1e12\n", "1000000000001\n", VerdictAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checker, func(t *testing.T) {
+			spec, err := checkerSpecFor(&modles.ProblemPropaty{Checker: tt.checker})
+			if err != nil {
+				t.Fatalf("checker spec: %v", err)
+			}
+			check, err := builtinChecker(spec)
+			if err != nil {
+				t.Fatalf("builtin checker: %v", err)
+			}
+
+			if got, message := check("", tt.expected, tt.output); got != tt.want {
+				t.Errorf("%q against %q = %s (%s), want %s", tt.output, tt.expected, got, message, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckerSpecRejectsUnknownAndMissingSource(t *testing.T) {
+	if _, err := checkerSpecFor(&modles.ProblemPropaty{Checker: "fuzzy"}); err == nil {
+		t.Error("unknown checker was accepted")
+	}
+	if _, err := checkerSpecFor(&modles.ProblemPropaty{Checker: CheckerCustom}); err == nil {
+		t.Error("custom checker without source was accepted")
+	}
+}
+
+func TestCheckerVerdict(t *testing.T) {
+	tests := []struct {
+		exit int
+		want Verdict
+	}{
+		{0, VerdictAccepted},
+		{1, VerdictWrongAnswer},
+		{2, VerdictWrongAnswer},
+		{3, VerdictJudgeError},
+		{-1, VerdictJudgeError},
+	}
+
+	for _, tt := range tests {
+		if got, _ := checkerVerdict(tt.exit, ""); got != tt.want {
+			t.Errorf("exit %d = %s, want %s", tt.exit, got, tt.want)
+		}
+	}
+}
+
+// sumChecker accepts any pair of numbers adding up to the input's single number
+const sumChecker = `#include <fstream>
+#include <iostream>
+int main(int argc, char **argv) {
+	std::ifstream in(argv[1]), out(argv[2]);
+	long long n, a, b;
+	in >> n;
+	if (!(out >> a >> b)) { std::cerr << "expected two numbers"; return 2; }
+	if (a + b != n) { std::cerr << a << " + " << b << " is not " << n; return 1; }
+	std::cerr << "ok";
+	return 0;
+}
+`
+
+func TestJudgeCustomChecker(t *testing.T) {
+	sandbox, _ := localTestSandbox(t)
+
+	problem := testProblem()
+	problem.Checker = CheckerCustom
+	problem.CheckerSource = sumChecker
+
+	tests := []struct {
+		name    string
+		code    string
+		want    Verdict
+		message string
+	}{
+		{
+			name:    "any split",
+			code:    "#include <iostream>\nint main() { long long n; std::cin >> n; std::cout << n - 1 << ' ' << 1 << std::endl; }",
+			want:    VerdictAccepted,
+			message: "ok",
+		},
+		{
+			name:    "wrong sum",
+			code:    "#include <iostream>\nint main() { long long n; std::cin >> n; std::cout << n << ' ' << 1 << std::endl; }",
+			want:    VerdictWrongAnswer,
+			message: "is not",
+		},
+		{
+			name:    "presentation",
+			code:    "#include <iostream>\nint main() { std::cout << \"no idea\" << std::endl; }",
+			want:    VerdictWrongAnswer,
+			message: "expected two numbers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCases := []TestCase{{Input: "10\n", Hidden: true}}
+			result, err := judgeProblem(sandbox, 0, problem, DefaultLanguage, tt.code, testCases, RunAll, nil)
+			if err != nil {
+				t.Fatalf("judge failed: %v", err)
+			}
+
+			c := result.Cases[0]
+			if c.Verdict != tt.want || !strings.Contains(c.CheckerMessage, tt.message) {
+				t.Errorf("got %s %q, want %s containing %q", c.Verdict, c.CheckerMessage, tt.want, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package cppruner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compile cache outcomes reported in JudgeMeta.CompileCache
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// CompileCache keeps build artifacts and compile errors on disk keyed by a hash of
+// the toolchain image, the compile command and the fully assembled source. Entries
+// are evicted least recently used first once the cache grows past maxBytes.
+type CompileCache struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+}
+
+var (
+	defaultCompileCache     *CompileCache
+	defaultCompileCacheOnce sync.Once
+)
+
+// DefaultCompileCache returns the cache configured by JUDGE_COMPILE_CACHE_DIR and
+// JUDGE_COMPILE_CACHE_MB, nil when the size is 0 or the directory can't be created
+func DefaultCompileCache() *CompileCache {
+	defaultCompileCacheOnce.Do(func() {
+		dir := os.Getenv("JUDGE_COMPILE_CACHE_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "codewar-compile-cache")
+		}
+
+		sizeMB := int64(512)
+		if v := os.Getenv("JUDGE_COMPILE_CACHE_MB"); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+				sizeMB = n
+			}
+		}
+		if sizeMB == 0 {
+			fmt.Println("Compile cache disabled")
+			return
+		}
+
+		cache, err := NewCompileCache(dir, sizeMB*1024*1024)
+		if err != nil {
+			fmt.Printf("Compile cache disabled: %v\n", err)
+			return
+		}
+		defaultCompileCache = cache
+	})
+	return defaultCompileCache
+}
+
+func NewCompileCache(dir string, maxBytes int64) (*CompileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create compile cache directory: %v", err)
+	}
+	return &CompileCache{dir: dir, maxBytes: maxBytes}, nil
+}
+
+// Key hashes everything that influences the build output
+func (c *CompileCache) Key(image string, compileCmd []string, source []byte) string {
+	h := sha256.New()
+	h.Write([]byte(image))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(compileCmd, "\x00")))
+	h.Write([]byte{0})
+	h.Write(source)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// compile returns a cached build for key or runs build and stores its outcome.
+// Artifacts are the top level files build creates or changes in dir.
+func (c *CompileCache) compile(key, dir string, build func() ([]byte, error)) (string, error) {
+	entry := filepath.Join(c.dir, key)
+
+	c.mu.Lock()
+	status, statusErr := os.ReadFile(filepath.Join(entry, "status"))
+	if statusErr == nil {
+		// Touch the entry so LRU eviction sees it as fresh
+		now := time.Now()
+		os.Chtimes(entry, now, now)
+	}
+	c.mu.Unlock()
+
+	if statusErr == nil {
+		output, _ := os.ReadFile(filepath.Join(entry, "compile_output.txt"))
+		if string(status) == "error" {
+			return CacheHit, &CompileError{Output: string(output)}
+		}
+		if err := copyFiles(filepath.Join(entry, "files"), dir); err == nil {
+			return CacheHit, nil
+		}
+		// A half deleted entry is just a miss
+	}
+
+	before := snapshot(dir)
+	output, err := build()
+
+	if compileErr, ok := err.(*CompileError); ok {
+		c.store(key, dir, nil, compileErr.Output, true)
+		return CacheMiss, err
+	}
+	if err != nil {
+		return CacheMiss, err
+	}
+
+	var artifacts []string
+	for name, modTime := range snapshot(dir) {
+		if prev, ok := before[name]; !ok || !prev.Equal(modTime) {
+			artifacts = append(artifacts, name)
+		}
+	}
+	c.store(key, dir, artifacts, string(output), false)
+
+	return CacheMiss, nil
+}
+
+func (c *CompileCache) store(key, dir string, artifacts []string, output string, failed bool) {
+	tmp, err := os.MkdirTemp(c.dir, "tmp_*")
+	if err != nil {
+		fmt.Printf("Failed to store compile cache entry: %v\n", err)
+		return
+	}
+
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+
+	err = os.MkdirAll(filepath.Join(tmp, "files"), 0755)
+	for _, name := range artifacts {
+		if err != nil {
+			break
+		}
+		err = copyFile(filepath.Join(dir, name), filepath.Join(tmp, "files", name))
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(tmp, "compile_output.txt"), []byte(output), 0644)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(tmp, "status"), []byte(status), 0644)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		// Another worker may have stored the same key meanwhile, either copy is fine
+		os.RemoveAll(filepath.Join(c.dir, key))
+		err = os.Rename(tmp, filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		fmt.Printf("Failed to store compile cache entry: %v\n", err)
+		os.RemoveAll(tmp)
+		return
+	}
+
+	c.evict()
+}
+
+// evict deletes least recently used entries until the cache fits, caller holds c.mu
+func (c *CompileCache) evict() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type cached struct {
+		path   string
+		size   int64
+		usedAt time.Time
+	}
+
+	var all []cached
+	var total int64
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), "tmp_") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(c.dir, e.Name())
+		size := dirSize(path)
+		all = append(all, cached{path: path, size: size, usedAt: info.ModTime()})
+		total += size
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].usedAt.Before(all[j].usedAt) })
+	for _, e := range all {
+		if total <= c.maxBytes {
+			return
+		}
+		os.RemoveAll(e.path)
+		total -= e.size
+	}
+}
+
+// snapshot maps the top level regular files in dir to their modification time
+func snapshot(dir string) map[string]time.Time {
+	files := map[string]time.Time{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return files
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files[e.Name()] = info.ModTime()
+		}
+	}
+	return files
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// copyFiles copies every regular file in src into dst
+func copyFiles(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -14,194 +14,517 @@ import (
 )
 
 type JudgeResult struct {
-	Passed      int   `json:"passed"`
-	Total       int   `json:"total"`
-	FailedCases []int `json:"failed_cases"`
+	Passed      int          `json:"passed"`
+	Total       int          `json:"total"`
+	FailedCases []int        `json:"failed_cases"`
+	Verdict     Verdict      `json:"verdict"`
+	Cases       []TestResult `json:"cases"`
+	// Score is out of MaxScore, by subtask when the problem has them
+	Score    float64         `json:"score"`
+	Subtasks []SubtaskResult `json:"subtasks,omitempty"`
+	// CompileErrors are the compiler's diagnostics when the verdict is CE
+	CompileErrors []Diagnostic `json:"compile_errors,omitempty"`
+	Meta          JudgeMeta    `json:"meta"`
+	// Hack, Stress and Reference are the reports of those jobs, nil for everything else
+	Hack      *HackOutcome     `json:"hack,omitempty"`
+	Stress    *StressReport    `json:"stress,omitempty"`
+	Reference *ReferenceReport `json:"reference,omitempty"`
+}
+
+// JudgeMeta describes how a submission was judged rather than how it did
+type JudgeMeta struct {
+	// CompileCache is "hit" or "miss", empty for interpreted languages or with the cache disabled
+	CompileCache string `json:"compile_cache,omitempty"`
+	// Limits are the enforced limits after language scaling
+	Limits Limits `json:"limits"`
 }
 
 type TestCase struct {
-	Input          string `json:"input"`
-	ExpectedOutput string `json:"expected_output"`
+	Input          string  `json:"input"`
+	ExpectedOutput string  `json:"expected_output"`
+	Hidden         bool    `json:"hidden"`
+	Group          int     `json:"group,omitempty"`
+	Weight         float64 `json:"weight,omitempty"`
+}
+
+// HiddenTestCases converts the problem's stored test cases for the judge
+func HiddenTestCases(problem *modles.ProblemPropaty) []TestCase {
+	var testCases []TestCase
+	for _, tc := range problem.TestCases {
+		testCases = append(testCases, TestCase{
+			Input:          tc.Input,
+			ExpectedOutput: tc.ExpectedOutput,
+			Hidden:         true,
+			Group:          tc.Group,
+			Weight:         tc.Weight,
+		})
+	}
+	return testCases
+}
+
+// JudgeCode judges userID's code against testCases in sandbox, DefaultSandbox unless a caller injects its own.
+// Every test case is run, stop picks whether judging ends at the first failure instead.
+func JudgeCode(sandbox SandboxBackend, userID uint, problemId uint, language string, code string, testCases []TestCase, stop StopPolicy, db *database.Databse) (JudgeResult, error) {
+	return judgeCode(sandbox, userID, problemId, language, code, testCases, stop, db, nil)
+}
+
+func judgeCode(sandbox SandboxBackend, userID uint, problemId uint, language string, code string, testCases []TestCase, stop StopPolicy, db *database.Databse, progress progressFunc) (JudgeResult, error) {
+	problem, err := fetchProblem(problemId, db)
+	if err != nil {
+		return JudgeResult{}, err
+	}
+	return judgeProblem(sandbox, userID, problem, language, code, testCases, stop, progress)
+}
+
+// judgeProblem judges code against testCases of an already loaded problem and scores it by the problem's subtasks
+func judgeProblem(sandbox SandboxBackend, userID uint, problem *modles.ProblemPropaty, language string, code string, testCases []TestCase, stop StopPolicy, progress progressFunc) (JudgeResult, error) {
+	task, err := newProblemTask(sandbox, userID, problem, language, code)
+	if err != nil {
+		return JudgeResult{}, err
+	}
+	defer os.RemoveAll(task.dir) // Clean up when done
+
+	task.testCases = testCases
+	task.stop = stop
+	task.progress = progress
+
+	result, err := task.judge()
+	if err == nil {
+		score(&result, testCases, problem.Subtasks)
+	}
+	return result, err
 }
 
-func JudgeCode(problemId uint, code string, testCases []TestCase, db *database.Databse) (JudgeResult, error) {
-	fmt.Println("Starting judge process...")
-	
+// loadProblem fetches a problem through the cache, or straight from the database without Redis
+func loadProblem(problemId uint, db *database.Databse) (*modles.ProblemPropaty, error) {
+	if db.Cache != nil {
+		return db.Cache.GetProblemById(problemId)
+	}
+
+	var problem modles.ProblemPropaty
+	err := db.Db.Preload("Examples").Preload("Templates").Preload("Subtasks").Where("id = ?", problemId).First(&problem).Error
+	return &problem, err
+}
+
+// fetchProblem loads the problem a submission is judged against
+func fetchProblem(problemId uint, db *database.Databse) (*modles.ProblemPropaty, error) {
+	// Fetch header file and main func using cache
+	problem, err := loadProblem(problemId, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch main and header file: %v", err)
+	}
+	return problem, nil
+}
+
+// newJudgeTask loads problemId and sets up a task for it like newProblemTask
+func newJudgeTask(sandbox SandboxBackend, userID uint, problemId uint, language string, code string, db *database.Databse) (*judgeTask, *modles.ProblemPropaty, error) {
+	problem, err := fetchProblem(problemId, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	task, err := newProblemTask(sandbox, userID, problem, language, code)
+	return task, problem, err
+}
+
+// newProblemTask writes the assembled source into a fresh temp directory, the caller
+// fills in the test cases and removes task.dir when done
+func newProblemTask(sandbox SandboxBackend, userID uint, problem *modles.ProblemPropaty, language string, code string) (*judgeTask, error) {
+	lang, err := GetLanguage(language)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Starting judge process for %s...\n", lang.Name())
+
+	spec, err := checkerSpecFor(problem)
+	if err != nil {
+		return nil, err
+	}
+
+	// Interactive problems are judged by the interactor instead of a checker
+	if problem.Interactive && problem.InteractorSource == "" {
+		return nil, fmt.Errorf("problem %d is interactive but has no interactor source", problem.ID)
+	}
+
 	// Create temp directory for this submission
 	tempDir, err := os.MkdirTemp("", "submission_*")
 	if err != nil {
-		return JudgeResult{}, fmt.Errorf("failed to create temp directory: %v", err)
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
 	}
-	defer os.RemoveAll(tempDir) // Clean up when done
 
 	fmt.Printf("Created temp directory: %s\n", tempDir)
 
-	// Define file paths
-	codeFile := filepath.Join(tempDir, "submission.cpp")
-	binFile := "submission.out"
-	inputFile := filepath.Join(tempDir, "input.txt")
-	outputFile := filepath.Join(tempDir, "output.txt")
+	task := &judgeTask{
+		sandbox:     sandbox,
+		userID:      userID,
+		problemID:   problem.ID,
+		lang:        lang,
+		dir:         tempDir,
+		checker:     spec,
+		interactive: problem.Interactive,
+		limits:      limitsFor(problem, lang),
 
-	// Fetch header file and main func using cache
-	var problem *modles.ProblemPropaty
+		interactorSource: problem.InteractorSource,
+	}
+	task.meta.Limits = task.limits
 
-	if db.Cache != nil {
-		// Use cache
-		problem, err = db.Cache.GetProblemById(problemId)
-	} else {
-		// Fallback to direct DB query
-		var p modles.ProblemPropaty
-		err = db.Db.Select("hader_file", "main_func").Where("id = ?", problemId).First(&p).Error
-		problem = &p
+	if err := task.writeSources(problem, code); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
 	}
 
+	return task, nil
+}
+
+// writeSources joins the header + user code + main file of the chosen language into the
+// source file, the checker and interactor are kept out of the submission's directory
+func (t *judgeTask) writeSources(problem *modles.ProblemPropaty, code string) error {
+	header, mainFunc := templateFor(problem, t.lang)
+	fullCode := header + "\n" + code + "\n" + mainFunc
+	t.code = newCodeRegion(t.lang.SourceFile(), header, code)
+	fmt.Println("Generated full code, writing to file...")
+
+	if err := os.WriteFile(filepath.Join(t.dir, t.lang.SourceFile()), []byte(fullCode), 0644); err != nil {
+		return fmt.Errorf("failed to write the full code in the source file: %v", err)
+	}
+	return nil
+}
+
+// judge builds the submission and any helper programs, then runs every test case
+// through the task's sandbox backend
+func (t *judgeTask) judge() (JudgeResult, error) {
+	image := t.lang.Image()
+	box, err := t.sandbox.NewSandbox(image, t.dir)
 	if err != nil {
-		return JudgeResult{}, fmt.Errorf("failed to fetch main and header file: %v", err)
+		return JudgeResult{}, err
 	}
+	defer box.Cleanup()
 
-	// Join the header + user code + main file in the code
-	fullCode := problem.HaderFile + "\n" + code + "\n" + problem.MainFunc
-	fmt.Println("Generated full code, writing to file...")
-	
-	err = os.WriteFile(codeFile, []byte(fullCode), 0644)
-	if err != nil {
-		return JudgeResult{}, fmt.Errorf("failed to write the full code in the c++ file: %v", err)
-	}
-
-	// Check if Docker is available
-	fmt.Println("Checking Docker availability...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	
-	dockerCheck := exec.CommandContext(ctx, "docker", "version")
-	if err := dockerCheck.Run(); err != nil {
-		return JudgeResult{}, fmt.Errorf("docker is not available or not running: %v", err)
-	}
-	fmt.Println("Docker is available")
-
-	// Check if gcc image exists locally
-	fmt.Println("Checking for gcc:latest image...")
-	ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel2()
-	
-	imageCheck := exec.CommandContext(ctx2, "docker", "images", "-q", "gcc:latest")
-	output, err := imageCheck.Output()
-	
-	if err != nil || len(strings.TrimSpace(string(output))) == 0 {
-		fmt.Println("gcc:latest image not found locally, pulling...")
-		// Pull with timeout
-		ctx3, cancel3 := context.WithTimeout(context.Background(), 5*time.Minute)
-		defer cancel3()
-		
-		pullCmd := exec.CommandContext(ctx3, "docker", "pull", "gcc:latest")
-		pullOutput, pullErr := pullCmd.CombinedOutput()
-		if pullErr != nil {
-			fmt.Printf("Failed to pull gcc image: %v\n", pullErr)
-			fmt.Printf("Pull output: %s\n", string(pullOutput))
-			return JudgeResult{}, fmt.Errorf("failed to pull gcc image: %v", pullErr)
+	// Compile with timeout, interpreted languages skip this step
+	if compileCmd := t.lang.CompileCommand(); len(compileCmd) > 0 {
+		fmt.Println("Compiling code...")
+		t.progress.report(StatusCompiling, 0)
+		err := t.compileSubmission(func() ([]byte, error) {
+			acquireRunSlot()
+			defer releaseRunSlot()
+			return box.Compile(compileCmd)
+		})
+		if err != nil {
+			result := JudgeResult{Total: len(t.testCases), Verdict: compileVerdict(err), Meta: t.meta}
+			if compileErr, ok := err.(*CompileError); ok {
+				compileErr.Diagnostics = parseDiagnostics(compileErr.Output, t.code)
+				result.CompileErrors = compileErr.Diagnostics
+			}
+			return result, err
 		}
-		fmt.Println("Successfully pulled gcc:latest image")
-	} else {
-		fmt.Println("gcc:latest image found locally")
-	}
-
-	// Compile inside Docker with timeout
-	fmt.Println("Compiling code...")
-	ctx4, cancel4 := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel4()
-	
-	cmd := exec.CommandContext(ctx4, "docker", "run", "--rm",
-		"-v", fmt.Sprintf("%s:/code", tempDir),
-		"-w", "/code",
-		"--memory=512m",      // Increased memory limit
-		"--cpus=1.0",         // CPU limit
-		"--network=none",     // No network access
-		"gcc:latest",
-		"g++", "-o", binFile, "submission.cpp", "-std=c++17")
-
-	fmt.Printf("Running compilation command: %v\n", cmd.Args)
-	
-	compileOutput, err := cmd.CombinedOutput()
-	if err != nil {
-		if ctx4.Err() == context.DeadlineExceeded {
-			return JudgeResult{}, fmt.Errorf("compilation timed out after 30 seconds")
+		fmt.Println("Compilation successful!")
+	}
+
+	helperDir, err := t.compileHelpers()
+	if err != nil {
+		return JudgeResult{}, err
+	}
+	if helperDir != "" {
+		defer os.RemoveAll(helperDir)
+	}
+
+	cases, err := t.runTests(box, helperDir)
+	if err != nil {
+		return JudgeResult{}, err
+	}
+	return buildResult(cases, t.meta), nil
+}
+
+// checkFunc compares outputs for tests run on lane, a custom checker runs in the lane's helper box
+func (t *judgeTask) checkFunc(lane *testLane) (checkFunc, error) {
+	if t.checker.name != CheckerCustom {
+		return builtinChecker(t.checker)
+	}
+
+	return func(input, expected, output string) (Verdict, string) {
+		if err := lane.helpers.WriteInput(input); err != nil {
+			fmt.Printf("Failed to hand the checker its input: %v\n", err)
+			return VerdictJudgeError, ""
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		acquireRunSlot()
+		out, err := lane.helpers.Run(ctx, checkerCommand(len(output)), output+expected, t.limits)
+		releaseRunSlot()
+		return checkerVerdict(exitCode(err), string(out))
+	}, nil
+}
+
+// runTest runs test case i on lane and classifies the outcome
+func (t *judgeTask) runTest(lane *testLane, i int) (TestResult, error) {
+	tc := t.testCases[i]
+	fmt.Printf("Running test case %d...\n", i+1)
+
+	// Interactive tests hand the input to the interactor only, it never lands where the program could read it
+	if !t.interactive {
+		if err := lane.box.WriteInput(tc.Input); err != nil {
+			return TestResult{}, err
 		}
-		fmt.Printf("Docker command failed: %v\n", err)
-		fmt.Printf("Compile output: %s\n", string(compileOutput))
-		return JudgeResult{}, fmt.Errorf("compilation failed: %s", string(compileOutput))
 	}
-	
-	fmt.Println("Compilation successful!")
-	
-	// Check if binary was created
-	binaryPath := filepath.Join(tempDir, binFile)
-	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
-		return JudgeResult{}, fmt.Errorf("compiled binary not found at %s", binaryPath)
+
+	acquireRunSlot()
+	ctx, cancel := context.WithTimeout(context.Background(), t.limits.killAfter())
+	started := time.Now()
+	var interactor interactorRun
+	var runErr error
+	if t.interactive {
+		interactor, runErr = t.interact(ctx, lane, tc.Input)
+	} else {
+		_, runErr = lane.box.Run(ctx, t.script(), "", t.limits)
 	}
+	elapsed := time.Since(started)
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	cancel()
+	releaseRunSlot()
 
-	passed := 0
-	var failedCases []int
+	var result TestResult
+	switch {
+	case timedOut:
+		fmt.Printf("Test case TIMEOUT (%v limit exceeded)\n", t.limits.killAfter())
+		result = TestResult{Hidden: tc.Hidden, Verdict: VerdictTimeLimit, TimeMs: elapsed.Milliseconds()}
+	case runErr != nil:
+		fmt.Printf("Test case execution error: %v\n", runErr)
+		result = TestResult{Hidden: tc.Hidden, Verdict: VerdictRuntimeError}
+	default:
+		result = classify(t, filepath.Join(lane.dir, resultDir), tc, elapsed, lane.check, interactor)
+	}
 
-	fmt.Printf("Running %d test cases...\n", len(testCases))
-	for i, tc := range testCases {
-		// Write input to file
-		err = os.WriteFile(inputFile, []byte(tc.Input), 0644)
-		if err != nil {
-			return JudgeResult{}, fmt.Errorf("failed to write input file: %v", err)
+	result.Case = i + 1
+	if result.Verdict == VerdictSecurityViolation {
+		logSecurityViolation(t.userID, t.problemID, result)
+	}
+	fmt.Printf("Test case %d: %s\n", i+1, result.Verdict)
+	return result, nil
+}
+
+// compileHelpers builds the problem's interactor and custom checker in a directory of their own,
+// which it returns ("" when the problem has neither) for the caller to remove. They are compiled
+// with the checker toolchain and linked statically so they run in any toolchain image.
+func (t *judgeTask) compileHelpers() (string, error) {
+	if !t.interactive && t.checker.name != CheckerCustom {
+		return "", nil
+	}
+
+	dir, err := os.MkdirTemp("", "helpers_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	if t.interactive {
+		err = writeHelperSource(dir, "interactor", t.interactorSource)
+	}
+	if err == nil && t.checker.name == CheckerCustom {
+		err = writeHelperSource(dir, "checker", t.checker.source)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	helpers, err := t.sandbox.NewSandbox(checkerImage, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	defer helpers.Cleanup()
+
+	acquireRunSlot()
+	defer releaseRunSlot()
+
+	if t.interactive {
+		if _, err := helpers.Compile(interactorCompileCommand); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to compile interactor: %v", err)
+		}
+	}
+	if t.checker.name == CheckerCustom {
+		if _, err := helpers.Compile(checkerCompileCommand); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to compile checker: %v", err)
 		}
+	}
+	return dir, nil
+}
 
-		fmt.Printf("Running test case %d...\n", i+1)
-		
-		// Run binary in Docker with input and timeout
-		ctx5, cancel5 := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel5()
-		
-		runCmd := exec.CommandContext(ctx5, "docker", "run", "--rm",
-			"-v", fmt.Sprintf("%s:/code", tempDir),
-			"-w", "/code",
-			"--memory=128m",      // Memory limit for execution
-			"--cpus=0.5",         // CPU limit for execution
-			"--network=none",     // No network access
-			"gcc:latest",
-			"timeout", "2", "sh", "-c", fmt.Sprintf("./%s < input.txt > output.txt", binFile))
-
-		runErr := runCmd.Run()
-		if runErr != nil {
-			if ctx5.Err() == context.DeadlineExceeded {
-				fmt.Printf("Test case %d: TIMEOUT (10s limit exceeded)\n", i+1)
-			} else {
-				fmt.Printf("Test case %d execution error: %v\n", i+1, runErr)
-			}
-			failedCases = append(failedCases, i+1)
-			continue
+// judgeTask is everything one submission needs on its way through a sandbox
+type judgeTask struct {
+	sandbox SandboxBackend
+	// userID and problemID are only used to report security violations
+	userID    uint
+	problemID uint
+	lang      Language
+	dir       string
+	// code is where the player's code sits in the assembled source, for compiler diagnostics
+	code      codeRegion
+	testCases []TestCase
+	checker   checkerSpec
+	limits    Limits
+	// interactive runs ./interactor against the program and takes its exit code as the verdict
+	interactive      bool
+	interactorSource string
+	// keepOutput reports the program's stdout in every TestResult, used by custom runs
+	keepOutput bool
+	stop       StopPolicy
+	progress   progressFunc
+	meta       JudgeMeta
+}
+
+// compileSubmission builds the submission through build, reusing the compile cache when one is configured
+func (t *judgeTask) compileSubmission(build func() ([]byte, error)) error {
+	cache := DefaultCompileCache()
+	if cache == nil {
+		_, err := build()
+		return err
+	}
+
+	source, err := os.ReadFile(filepath.Join(t.dir, t.lang.SourceFile()))
+	if err != nil {
+		return fmt.Errorf("failed to read the source file: %v", err)
+	}
+
+	key := cache.Key(t.lang.Image(), t.lang.CompileCommand(), source)
+	t.meta.CompileCache, err = cache.compile(key, t.dir, build)
+	fmt.Printf("Compile cache %s for %s\n", t.meta.CompileCache, key[:12])
+	return err
+}
+
+// script is the shell snippet that runs one test case inside the sandbox
+func (t *judgeTask) script() string {
+	return runScript(strings.Join(t.lang.RunCommand(), " "), t.limits, t.interactive)
+}
+
+// writeHelperSource stores a problem setter's program as <name>.cpp under dir, next to
+// the ./<name> binary it is built into
+func writeHelperSource(dir, name, source string) error {
+	if err := os.WriteFile(filepath.Join(dir, name+".cpp"), []byte(source), 0644); err != nil {
+		return fmt.Errorf("failed to write %s source: %v", name, err)
+	}
+	return nil
+}
+
+// classify reads what runScript left in dir and turns it into a verdict for tc,
+// interactor is how the interactor finished on interactive problems
+func classify(task *judgeTask, dir string, tc TestCase, elapsed time.Duration, check checkFunc, interactor interactorRun) TestResult {
+	result := TestResult{Hidden: tc.Hidden}
+
+	usage, err := readUsage(dir, task.limits.Time())
+	if err != nil {
+		fmt.Printf("Failed to read run statistics: %v\n", err)
+		result.Verdict = VerdictRuntimeError
+		return result
+	}
+	if usage.WallTime == 0 {
+		usage.WallTime = elapsed
+	}
+
+	result.TimeMs = usage.WallTime.Milliseconds()
+	result.CPUTimeMs = usage.CPUTime.Milliseconds()
+	result.MemoryKB = usage.MemoryKB
+	result.ExitCode = usage.ExitCode
+	result.Signal = usage.Signal
+	result.Stderr = truncate(usage.Stderr, maxStderrBytes)
+
+	// Whatever else went wrong, a program the sandbox had to stop is reported as such
+	switch {
+	case usage.SyscallBlocked:
+		result.Verdict, result.CheckerMessage = VerdictSecurityViolation, "blocked system call"
+		return result
+	case usage.PidsLimitHit:
+		result.Verdict, result.CheckerMessage = VerdictSecurityViolation, "process limit reached"
+		return result
+	}
+
+	// The interactor owns the verdict once the program stayed within its limits
+	if task.interactive {
+		switch {
+		case usage.TimedOut || usage.WallTime >= task.limits.Time():
+			result.Verdict = VerdictTimeLimit
+		case usage.OOMKilled || usage.MemoryKB >= task.limits.MemoryMB*1024:
+			result.Verdict = VerdictMemoryLimit
+		case usage.ExitCode != 0 && interactor.Exit == 0:
+			result.Verdict = VerdictRuntimeError
+		default:
+			result.Verdict, result.CheckerMessage = checkerVerdict(interactor.Exit, interactor.Message)
 		}
+		return result
+	}
 
-		// Read output
-		output, err := os.ReadFile(outputFile)
-		if err != nil {
-			fmt.Printf("Failed to read output file for test case %d: %v\n", i+1, err)
-			failedCases = append(failedCases, i+1)
-			continue
+	output, err := readResultFile(dir, "output.txt")
+	if err != nil {
+		fmt.Printf("Failed to read output file: %v\n", err)
+		result.Verdict = VerdictRuntimeError
+		return result
+	}
+	if task.keepOutput {
+		result.Stdout = truncate(string(output), maxStdoutBytes)
+	}
+
+	switch {
+	case usage.TimedOut || usage.WallTime >= task.limits.Time():
+		result.Verdict = VerdictTimeLimit
+	case usage.OOMKilled || usage.MemoryKB >= task.limits.MemoryMB*1024:
+		result.Verdict = VerdictMemoryLimit
+	case usage.Signal == sigXFSZ || int64(len(output)) > task.limits.OutputKB*1024:
+		result.Verdict = VerdictOutputLimit
+	case usage.ExitCode != 0:
+		result.Verdict = VerdictRuntimeError
+	default:
+		// Let the problem's checker compare the output
+		result.Verdict, result.CheckerMessage = check(tc.Input, tc.ExpectedOutput, string(output))
+		// Visible tests show where the answer went wrong, hidden ones stay opaque
+		if result.Verdict == VerdictWrongAnswer && !tc.Hidden {
+			result.Diff = diffOutputs(tc.ExpectedOutput, string(output))
 		}
+	}
 
-		// Compare output (trimming whitespace)
-		actual := strings.TrimSpace(string(output))
-		expected := strings.TrimSpace(tc.ExpectedOutput)
+	return result
+}
 
-		if actual == expected {
+// buildResult summarises per-test results into the JudgeResult sent to clients
+func buildResult(cases []TestResult, meta JudgeMeta) JudgeResult {
+	passed := 0
+	var failedCases []int
+	for _, c := range cases {
+		switch c.Verdict {
+		case VerdictAccepted:
 			passed++
-			fmt.Printf("Test case %d: PASSED\n", i+1)
-		} else {
-			failedCases = append(failedCases, i+1)
-			fmt.Printf("Test case %d: FAILED\n", i+1)
-			fmt.Printf("  Expected: '%s'\n", expected)
-			fmt.Printf("  Actual  : '%s'\n", actual)
+		case VerdictSkipped:
+		default:
+			failedCases = append(failedCases, c.Case)
 		}
 	}
 
 	return JudgeResult{
 		Passed:      passed,
-		Total:       len(testCases),
+		Total:       len(cases),
 		FailedCases: failedCases,
-	}, nil
-}
\ No newline at end of file
+		Verdict:     overallVerdict(cases),
+		Cases:       cases,
+		Meta:        meta,
+	}
+}
+
+// compileVerdict tells a failed build (CE) apart from a judge problem such as a timeout
+func compileVerdict(err error) Verdict {
+	if _, ok := err.(*CompileError); ok {
+		return VerdictCompileError
+	}
+	return ""
+}
+
+// exitCode extracts a process exit status, -1 when the process did not run at all
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
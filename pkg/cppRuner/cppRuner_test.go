@@ -0,0 +1,160 @@
+package cppruner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+func TestMain(m *testing.M) {
+	// Every test builds its own source, a shared cache would only hide compiler runs
+	os.Setenv("JUDGE_COMPILE_CACHE_MB", "0")
+	os.Exit(m.Run())
+}
+
+// localTestSandbox returns the local sandbox configured from the environment, skipping the
+// test where it can't run programs: no g++, no Linux or no user namespaces
+func localTestSandbox(t *testing.T) (SandboxBackend, LocalConfig) {
+	t.Helper()
+
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ is not installed")
+	}
+
+	cfg := LocalConfigFromEnv()
+	backend, err := NewLocalSandbox(cfg)
+	if err != nil {
+		t.Skipf("local sandbox unavailable: %v", err)
+	}
+
+	box, err := backend.NewSandbox(checkerImage, t.TempDir())
+	if err != nil {
+		t.Skipf("local sandbox unavailable: %v", err)
+	}
+	defer box.Cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if out, err := box.Run(ctx, "true", "", DefaultJudgeSettings().Limits); err != nil {
+		t.Skipf("local sandbox can't run programs (namespaces unavailable?): %v: %s", err, out)
+	}
+	return backend, cfg
+}
+
+// testProblem is a full-program a+b problem with tight limits
+func testProblem() *modles.ProblemPropaty {
+	return &modles.ProblemPropaty{
+		Title:         "A+B",
+		TimeLimitMs:   1000,
+		MemoryLimitMB: 64,
+		OutputLimitKB: 64,
+		StackLimitMB:  8,
+	}
+}
+
+var sumTests = []TestCase{
+	{Input: "1 2\n", ExpectedOutput: "3\n", Hidden: true},
+	{Input: "20 22\n", ExpectedOutput: "42\n", Hidden: true},
+}
+
+func TestJudgeVerdicts(t *testing.T) {
+	sandbox, cfg := localTestSandbox(t)
+
+	tests := []struct {
+		name string
+		code string
+		want Verdict
+		// needsCgroup marks verdicts only a memory cgroup can tell, ulimit -v turns them into RE
+		needsCgroup bool
+	}{
+		{
+			name: "accepted",
+			code: "#include <iostream>\nint main() { long long a, b; std::cin >> a >> b; std::cout << a + b << std::endl; }",
+			want: VerdictAccepted,
+		},
+		{
+			name: "wrong answer",
+			code: "#include <iostream>\nint main() { long long a, b; std::cin >> a >> b; std::cout << a - b << std::endl; }",
+			want: VerdictWrongAnswer,
+		},
+		{
+			name: "time limit",
+			code: "int main() { volatile unsigned long long n = 0; for (;;) n++; }",
+			want: VerdictTimeLimit,
+		},
+		{
+			name: "runtime error",
+			code: "#include <cstdlib>\nint main() { std::abort(); }",
+			want: VerdictRuntimeError,
+		},
+		{
+			name: "compile error",
+			code: "int main() { return missing; }",
+			want: VerdictCompileError,
+		},
+		{
+			name:        "memory limit",
+			code:        "#include <cstring>\n#include <cstdlib>\nint main() { for (;;) { char *p = (char *)malloc(1 << 20); if (!p) return 1; memset(p, 1, 1 << 20); } }",
+			want:        VerdictMemoryLimit,
+			needsCgroup: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.needsCgroup && cfg.CgroupParent == "" {
+				t.Skip("set JUDGE_LOCAL_CGROUP to a delegated cgroup v2 directory to measure memory")
+			}
+
+			result, err := judgeProblem(sandbox, 0, testProblem(), DefaultLanguage, tt.code, sumTests, StopOnFirstFailure, nil)
+			if tt.want == VerdictCompileError {
+				if _, ok := err.(*CompileError); !ok {
+					t.Fatalf("expected a compile error, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("judge failed: %v", err)
+			}
+
+			if result.Verdict != tt.want {
+				t.Errorf("verdict = %s, want %s (cases %+v)", result.Verdict, tt.want, result.Cases)
+			}
+		})
+	}
+}
+
+func TestJudgeScoresSubtasks(t *testing.T) {
+	sandbox, _ := localTestSandbox(t)
+
+	problem := testProblem()
+	problem.Subtasks = []modles.Subtask{
+		{Group: 1, Points: 30, Policy: PolicyMin},
+		{Group: 2, Points: 70, Policy: PolicySum},
+	}
+	testCases := []TestCase{
+		{Input: "1 2\n", ExpectedOutput: "3\n", Group: 1},
+		{Input: "5 5\n", ExpectedOutput: "10\n", Group: 2},
+		{Input: "-1 1\n", ExpectedOutput: "0\n", Group: 2},
+	}
+
+	// Right unless the answer is 0
+	code := "#include <iostream>\nint main() { long long a, b; std::cin >> a >> b; std::cout << (a + b == 0 ? 1 : a + b) << std::endl; }"
+	result, err := judgeProblem(sandbox, 0, problem, DefaultLanguage, code, testCases, RunAll, nil)
+	if err != nil {
+		t.Fatalf("judge failed: %v", err)
+	}
+
+	if result.Verdict != VerdictWrongAnswer || result.Passed != 2 {
+		t.Fatalf("verdict = %s with %d passed, want WA with 2", result.Verdict, result.Passed)
+	}
+	if result.Score != 65 {
+		t.Errorf("score = %v, want 65", result.Score)
+	}
+	// Visible tests carry a diff, the failing one is visible
+	if diff := result.Cases[2].Diff; diff == nil || diff.Expected != "0" || diff.Actual != "1" {
+		t.Errorf("diff = %+v, want expected 0 and actual 1", diff)
+	}
+}
@@ -0,0 +1,93 @@
+package cppruner
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDiagnostics caps the entries parsed from one compiler output, the first errors are the useful ones
+const maxDiagnostics = 50
+
+// Diagnostic is one compiler message. Line and Column count in the code the player wrote,
+// Line 0 means the message points into the problem's template (header or main).
+type Diagnostic struct {
+	Severity string `json:"severity"` // "error", "warning" or "note"
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+}
+
+// diagnosticLine matches "file:line:col: severity: message" as printed by g++, clang and javac (without a column)
+var diagnosticLine = regexp.MustCompile(`^(?:\./)?([^\s:]+):(\d+):(?:(\d+):)?\s*(fatal error|error|warning|note):\s*(.*)$`)
+
+// codeRegion is where the player's code sits in the assembled source file
+type codeRegion struct {
+	sourceFile string
+	// firstLine is the source line holding the first line of the player's code
+	firstLine int
+	lines     int
+}
+
+// newCodeRegion locates code in header + "\n" + code + "\n" + main as written by writeSources
+func newCodeRegion(sourceFile, header, code string) codeRegion {
+	return codeRegion{
+		sourceFile: sourceFile,
+		firstLine:  strings.Count(header, "\n") + 2,
+		lines:      strings.Count(code, "\n") + 1,
+	}
+}
+
+// parseDiagnostics turns compiler output into diagnostics with lines relative to the player's code.
+// Source excerpts, caret lines and messages about other files (system headers) are left out,
+// so nothing of the template is quoted back.
+func parseDiagnostics(output string, region codeRegion) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, raw := range strings.Split(output, "\n") {
+		m := diagnosticLine.FindStringSubmatch(strings.TrimRight(raw, "\r"))
+		if m == nil || filepath.Base(m[1]) != region.sourceFile {
+			continue
+		}
+
+		line, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		severity := m[4]
+		if severity == "fatal error" {
+			severity = "error"
+		}
+
+		d := Diagnostic{Severity: severity, Message: m[5]}
+		if rel := line - region.firstLine + 1; rel >= 1 && rel <= region.lines {
+			d.Line = rel
+			d.Column = column
+		}
+		diagnostics = append(diagnostics, d)
+
+		if len(diagnostics) == maxDiagnostics {
+			break
+		}
+	}
+	return diagnostics
+}
+
+// formatDiagnostics renders diagnostics one per line like "line 3:5: error: ..."
+func formatDiagnostics(diagnostics []Diagnostic) string {
+	var sb strings.Builder
+	for i, d := range diagnostics {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		switch {
+		case d.Line == 0:
+			sb.WriteString("template")
+		case d.Column > 0:
+			fmt.Fprintf(&sb, "line %d:%d", d.Line, d.Column)
+		default:
+			fmt.Fprintf(&sb, "line %d", d.Line)
+		}
+		fmt.Fprintf(&sb, ": %s: %s", d.Severity, d.Message)
+	}
+	return sb.String()
+}
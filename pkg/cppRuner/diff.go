@@ -0,0 +1,159 @@
+package cppruner
+
+import (
+	"strings"
+)
+
+// Bounds on the diff attached to a failed visible test case
+const (
+	maxDiffLines   = 200
+	maxDiffBytes   = 4 * 1024
+	maxSnippetSize = 120
+	snippetContext = 40
+)
+
+// OutputDiff shows where a wrong answer went wrong. It is only computed for visible
+// test cases, hidden ones never carry any of their content.
+type OutputDiff struct {
+	// Line and Token are 1-based and point at the first mismatch, Token is 0 when a whole line is missing
+	Line  int `json:"line"`
+	Token int `json:"token,omitempty"`
+	// Expected and Actual are the mismatching lines cut down around the first difference
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	// Diff is a line diff with "-" for expected and "+" for actual lines
+	Diff      string `json:"diff"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// diffOutputs compares expected and output line by line, ignoring trailing whitespace
+// and trailing blank lines like the lines checker does
+func diffOutputs(expected, output string) *OutputDiff {
+	want := normalizedLines(expected)
+	got := normalizedLines(output)
+
+	d := &OutputDiff{}
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if i < len(want) && i < len(got) && w == g {
+			continue
+		}
+
+		d.Line = i + 1
+		col := 0
+		if i < len(want) && i < len(got) {
+			d.Token, col = firstTokenMismatch(w, g)
+		}
+		d.Expected = snippet(w, col)
+		d.Actual = snippet(g, col)
+		break
+	}
+
+	d.Diff, d.Truncated = lineDiff(want, got)
+	return d
+}
+
+// firstTokenMismatch returns the 1-based index of the first differing whitespace separated
+// token of two lines and its byte offset in the expected line
+func firstTokenMismatch(want, got string) (int, int) {
+	wantTokens := strings.Fields(want)
+	gotTokens := strings.Fields(got)
+
+	offset := 0
+	for i := 0; i < len(wantTokens) || i < len(gotTokens); i++ {
+		if i < len(wantTokens) && i < len(gotTokens) && wantTokens[i] == gotTokens[i] {
+			offset = strings.Index(want[offset:], wantTokens[i]) + offset + len(wantTokens[i])
+			continue
+		}
+		if i < len(wantTokens) {
+			offset += strings.Index(want[offset:], wantTokens[i])
+		}
+		return i + 1, offset
+	}
+	// Same tokens, the lines only differ in spacing
+	return 0, 0
+}
+
+// snippet cuts line down to a window starting a little before col
+func snippet(line string, col int) string {
+	start := col - snippetContext
+	if start < 0 || start > len(line) {
+		start = 0
+	}
+	end := start + maxSnippetSize
+	if end > len(line) {
+		end = len(line)
+	}
+
+	s := strings.ToValidUTF8(line[start:end], "")
+	if start > 0 {
+		s = "..." + s
+	}
+	if end < len(line) {
+		s += "..."
+	}
+	return s
+}
+
+// lineDiff renders a longest-common-subsequence diff of the first maxDiffLines lines,
+// cut at maxDiffBytes. It reports whether anything was left out.
+func lineDiff(want, got []string) (string, bool) {
+	truncated := false
+	if len(want) > maxDiffLines {
+		want, truncated = want[:maxDiffLines], true
+	}
+	if len(got) > maxDiffLines {
+		got, truncated = got[:maxDiffLines], true
+	}
+
+	// lcs[i][j] is the LCS length of want[i:] and got[j:]
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var b strings.Builder
+	write := func(prefix, line string) bool {
+		entry := prefix + snippet(line, 0) + "\n"
+		if b.Len()+len(entry) > maxDiffBytes {
+			return false
+		}
+		b.WriteString(entry)
+		return true
+	}
+
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		var ok bool
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			ok = write("  ", want[i])
+			i, j = i+1, j+1
+		case i < len(want) && (j == len(got) || lcs[i+1][j] >= lcs[i][j+1]):
+			ok = write("- ", want[i])
+			i++
+		default:
+			ok = write("+ ", got[j])
+			j++
+		}
+		if !ok {
+			return b.String(), true
+		}
+	}
+	return b.String(), truncated
+}
@@ -0,0 +1,88 @@
+package cppruner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffOutputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		output   string
+		line     int
+		token    int
+		want     string
+		got      string
+		diff     string
+	}{
+		{
+			name:     "token mismatch",
+			expected: "1 2 3\n4 5 6\n",
+			output:   "1 2 3\n4 7 6\n",
+			line:     2,
+			token:    2,
+			want:     "4 5 6",
+			got:      "4 7 6",
+			diff:     "  1 2 3\n- 4 5 6\n+ 4 7 6\n",
+		},
+		{
+			name:     "missing line",
+			expected: "a\nb\n",
+			output:   "a\n",
+			line:     2,
+			want:     "b",
+			diff:     "  a\n- b\n",
+		},
+		{
+			name:     "extra line",
+			expected: "a\n",
+			output:   "a\nb\n",
+			line:     2,
+			got:      "b",
+			diff:     "  a\n+ b\n",
+		},
+		{
+			name:     "trailing whitespace is ignored",
+			expected: "a\nb\n",
+			output:   "a  \nc\n\n",
+			line:     2,
+			token:    1,
+			want:     "b",
+			got:      "c",
+			diff:     "  a\n- b\n+ c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := diffOutputs(tt.expected, tt.output)
+			if d.Line != tt.line || d.Token != tt.token || d.Expected != tt.want || d.Actual != tt.got {
+				t.Errorf("got line %d token %d %q/%q, want line %d token %d %q/%q",
+					d.Line, d.Token, d.Expected, d.Actual, tt.line, tt.token, tt.want, tt.got)
+			}
+			if d.Diff != tt.diff {
+				t.Errorf("diff = %q, want %q", d.Diff, tt.diff)
+			}
+		})
+	}
+}
+
+func TestDiffOutputsTruncates(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	var expected, output strings.Builder
+	for i := 0; i < maxDiffLines+10; i++ {
+		expected.WriteString(long + "\n")
+		output.WriteString(long + "y\n")
+	}
+
+	d := diffOutputs(expected.String(), output.String())
+	if !d.Truncated || len(d.Diff) > maxDiffBytes {
+		t.Errorf("diff of %d bytes, truncated %v, want at most %d bytes and truncated", len(d.Diff), d.Truncated, maxDiffBytes)
+	}
+	// Cut snippets are marked with an ellipsis on either side
+	limit := maxSnippetSize + 2*len("...")
+	if len(d.Expected) > limit || len(d.Actual) > limit || !strings.HasSuffix(d.Expected, "...") {
+		t.Errorf("snippets %q and %q, want cut to at most %d bytes", d.Expected, d.Actual, limit)
+	}
+}
@@ -0,0 +1,182 @@
+package cppruner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// validatorTimeout is what the validator may take on one hack input
+const validatorTimeout = 10 * time.Second
+
+// validatorCompileCommand builds validator.cpp into ./validator
+var validatorCompileCommand = helperCompileCommand("validator")
+
+// HackOutcome is how a hack input went
+type HackOutcome struct {
+	// Outcome is modles.HackSuccess, HackFailed or HackInvalid
+	Outcome string `json:"outcome"`
+	// Message is the validator's or the reference's complaint about an invalid input
+	Message        string  `json:"message,omitempty"`
+	ExpectedOutput string  `json:"expected_output,omitempty"`
+	Verdict        Verdict `json:"verdict,omitempty"`
+	CheckerMessage string  `json:"checker_message,omitempty"`
+}
+
+// JudgeHack checks input with the problem's validator, answers it with the reference solution
+// and judges the defending code (a submission in language) against that answer. Problems
+// without a validator or reference can't be hacked, nor can interactive ones.
+func JudgeHack(sandbox SandboxBackend, defenderID uint, problemId uint, language string, code string, input string, db *database.Databse) (HackOutcome, error) {
+	var outcome HackOutcome
+	if len(input) > MaxRunInputBytes {
+		return outcome, fmt.Errorf("input is too long (maximum %d bytes)", MaxRunInputBytes)
+	}
+
+	problem, err := loadProblem(problemId, db)
+	if err != nil {
+		return outcome, fmt.Errorf("failed to fetch problem: %v", err)
+	}
+	switch {
+	case problem.Interactive:
+		return outcome, fmt.Errorf("problem %d is interactive and can't be hacked", problemId)
+	case problem.ValidatorSource == "" || problem.ReferenceSource == "":
+		return outcome, fmt.Errorf("problem %d has no validator or reference solution, hacks are disabled", problemId)
+	}
+
+	message, err := validateInput(sandbox, problem.ValidatorSource, input)
+	if err != nil {
+		return outcome, err
+	}
+	if message != "" {
+		outcome.Outcome = modles.HackInvalid
+		outcome.Message = message
+		return outcome, nil
+	}
+
+	cases, err := runReference(sandbox, problem, []string{input}, db)
+	if err != nil {
+		return outcome, err
+	}
+	if c := cases[0]; c.Verdict != VerdictOK || len(c.Stdout) > maxStdoutBytes {
+		// The validator let it through but the reference can't answer it within the limits
+		outcome.Outcome = modles.HackInvalid
+		outcome.Message = fmt.Sprintf("reference solution got %s on this input", c.Verdict)
+		return outcome, nil
+	}
+	outcome.ExpectedOutput = cases[0].Stdout
+
+	result, err := judgeCode(sandbox, defenderID, problemId, language, code, []TestCase{{Input: input, ExpectedOutput: outcome.ExpectedOutput, Hidden: true}}, RunAll, db, nil)
+	if err != nil {
+		// Accepted code that no longer builds is the judge's problem, not a broken solution
+		return outcome, err
+	}
+
+	outcome.Verdict = result.Verdict
+	if len(result.Cases) > 0 {
+		outcome.CheckerMessage = result.Cases[0].CheckerMessage
+	}
+	outcome.Outcome, err = hackOutcome(result.Verdict)
+	return outcome, err
+}
+
+// hackOutcome maps the defending code's verdict on a hack input: a wrong answer or a
+// blown limit breaks it, passing survives it, anything else says nothing about the code
+func hackOutcome(verdict Verdict) (string, error) {
+	switch verdict {
+	case VerdictWrongAnswer, VerdictTimeLimit, VerdictMemoryLimit, VerdictRuntimeError:
+		return modles.HackSuccess, nil
+	case VerdictAccepted:
+		return modles.HackFailed, nil
+	}
+	return "", fmt.Errorf("defending code got %s, the hack can't be judged", verdict)
+}
+
+// HackOn judges a hack through judge, on the judge nodes when there are any, like JudgeHack does in-process
+func HackOn(judge JudgeBackend, defenderID uint, problemId uint, language string, code string, input string) (HackOutcome, error) {
+	result, err := judge.Judge(JudgeJob{
+		ProblemID: problemId,
+		UserID:    defenderID,
+		Language:  language,
+		Code:      code,
+		Hack:      true,
+		HackInput: input,
+	}, nil)
+	if err != nil {
+		return HackOutcome{}, err
+	}
+	if result.Hack == nil {
+		return HackOutcome{}, fmt.Errorf("judge returned no hack outcome")
+	}
+	return *result.Hack, nil
+}
+
+// validateInput runs the problem's validator on input, which exits 0 for a valid input.
+// The returned message is empty for a valid input and the validator's stderr otherwise.
+func validateInput(sandbox SandboxBackend, source string, input string) (string, error) {
+	dir, err := os.MkdirTemp("", "validator_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeHelperSource(dir, "validator", source); err != nil {
+		return "", err
+	}
+
+	box, err := sandbox.NewSandbox(checkerImage, dir)
+	if err != nil {
+		return "", err
+	}
+	defer box.Cleanup()
+
+	acquireRunSlot()
+	_, err = box.Compile(validatorCompileCommand)
+	releaseRunSlot()
+	if err != nil {
+		return "", fmt.Errorf("failed to compile validator: %v", err)
+	}
+
+	if err := box.WriteInput(input); err != nil {
+		return "", err
+	}
+
+	script := fmt.Sprintf(`mkdir -p out && find out -mindepth 1 -delete
+timeout -s KILL %.0f ./validator < %s > /dev/null 2> out/validator_stderr.txt
+echo $? > out/validator_exit.txt`, validatorTimeout.Seconds(), inputFile)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*validatorTimeout)
+	defer cancel()
+
+	acquireRunSlot()
+	out, err := box.Run(ctx, script, "", DefaultJudgeSettings().Limits)
+	releaseRunSlot()
+	if err != nil {
+		return "", fmt.Errorf("validator failed: %v: %s", err, truncate(string(out), maxStderrBytes))
+	}
+
+	exit, err := readResultFile(filepath.Join(dir, resultDir), "validator_exit.txt")
+	if err != nil {
+		return "", fmt.Errorf("validator left no exit code: %v", err)
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(exit)))
+	if err != nil {
+		return "", fmt.Errorf("validator left a malformed exit code: %q", exit)
+	}
+	if code == 0 {
+		return "", nil
+	}
+
+	stderr, _ := readResultFile(filepath.Join(dir, resultDir), "validator_stderr.txt")
+	message := strings.TrimSpace(truncate(string(stderr), maxStderrBytes))
+	if message == "" {
+		message = fmt.Sprintf("validator rejected the input (exit code %d)", code)
+	}
+	return message, nil
+}
@@ -0,0 +1,32 @@
+package cppruner
+
+import (
+	"testing"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+func TestHackOutcome(t *testing.T) {
+	tests := []struct {
+		verdict Verdict
+		want    string
+		wantErr bool
+	}{
+		{VerdictWrongAnswer, modles.HackSuccess, false},
+		{VerdictTimeLimit, modles.HackSuccess, false},
+		{VerdictMemoryLimit, modles.HackSuccess, false},
+		{VerdictRuntimeError, modles.HackSuccess, false},
+		{VerdictAccepted, modles.HackFailed, false},
+		{VerdictCompileError, "", true},
+		{VerdictJudgeError, "", true},
+		{VerdictOutputLimit, "", true},
+		{VerdictSecurityViolation, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := hackOutcome(tt.verdict)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("hackOutcome(%s) = %q, %v; want %q, error %v", tt.verdict, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cppruner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// interactorRun is how the interactor finished one interactive test
+type interactorRun struct {
+	Exit    int
+	Message string
+}
+
+// interactorScript starts ./interactor on the test input, talking to the program over the script's
+// stdin and stdout. It gets twice the time limit plus a second to deliver its verdict.
+func interactorScript(limit time.Duration) string {
+	return fmt.Sprintf(`timeout -s KILL %.3f ./interactor %s "${TMPDIR:-/tmp}/interactor.txt"`, (2*limit + time.Second).Seconds(), inputFile)
+}
+
+// interact runs one interactive test: the program in the lane's box and ./interactor in the
+// lane's helper box, the interactor's stdout feeds the program's stdin and the other way round.
+// Only the helper box ever gets the test input, and the program never runs where the
+// interactor does, so it can neither read the hidden test data nor tamper with the verdict.
+func (t *judgeTask) interact(ctx context.Context, lane *testLane, input string) (interactorRun, error) {
+	var interactor interactorRun
+	if err := lane.helpers.WriteInput(input); err != nil {
+		return interactor, err
+	}
+
+	toProgram, fromInteractor := io.Pipe()
+	toInteractor, fromProgram := io.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stderr, err := lane.helpers.Pipe(ctx, interactorScript(t.limits.Time()), toInteractor, fromInteractor, t.limits)
+		// Nobody reads what the program writes anymore
+		toInteractor.Close()
+		interactor.Exit = exitCode(err)
+		interactor.Message = string(stderr)
+	}()
+
+	_, runErr := lane.box.Pipe(ctx, t.script(), toProgram, fromProgram, t.limits)
+	toProgram.Close()
+	<-done
+	return interactor, runErr
+}
+
+// interactorCompileCommand builds interactor.cpp into ./interactor
+var interactorCompileCommand = helperCompileCommand("interactor")
@@ -0,0 +1,77 @@
+package cppruner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+)
+
+// JudgeBackend judges jobs, in this process or on judge nodes. Errors keep their meaning
+// across backends: a failed build is a *CompileError next to a CE result.
+type JudgeBackend interface {
+	// Judge judges job, or runs, hacks, stress tests or regenerates outputs when one of
+	// those kinds is set, reporting progress (may be nil) as it goes
+	Judge(job JudgeJob, progress func(status SubmissionStatus, test int)) (JudgeResult, error)
+}
+
+// localJudge judges in this process through a sandbox backend
+type localJudge struct {
+	sandbox SandboxBackend
+	db      *database.Databse
+}
+
+// NewLocalJudge judges in-process with sandbox, what judge nodes and development setups use
+func NewLocalJudge(sandbox SandboxBackend, db *database.Databse) JudgeBackend {
+	return &localJudge{sandbox: sandbox, db: db}
+}
+
+func (j *localJudge) Judge(job JudgeJob, progress func(status SubmissionStatus, test int)) (JudgeResult, error) {
+	if job.Run {
+		return RunCode(j.sandbox, job.UserID, job.ProblemID, job.Language, job.Code, job.Inputs, j.db)
+	}
+	if job.Hack {
+		outcome, err := JudgeHack(j.sandbox, job.UserID, job.ProblemID, job.Language, job.Code, job.HackInput, j.db)
+		if err != nil {
+			return JudgeResult{}, err
+		}
+		return JudgeResult{Verdict: outcome.Verdict, Hack: &outcome}, nil
+	}
+	if job.Stress {
+		report, err := StressTest(j.sandbox, job.ProblemID, job.Language, job.Code, job.StressRuns, job.StressSeed, j.db)
+		if err != nil {
+			return JudgeResult{}, err
+		}
+		return JudgeResult{Stress: &report}, nil
+	}
+	if job.Reference {
+		report, err := GenerateExpectedOutputs(j.sandbox, job.ProblemID, j.db)
+		if err != nil {
+			return JudgeResult{}, err
+		}
+		return JudgeResult{Reference: &report}, nil
+	}
+	return judgeCode(j.sandbox, job.UserID, job.ProblemID, job.Language, job.Code, job.TestCases, job.StopPolicy, j.db, progress)
+}
+
+// DefaultJudge sends jobs to the judge nodes listed in JUDGE_NODES (comma separated base URLs)
+// and judges in-process with sandbox when none are configured
+func DefaultJudge(sandbox SandboxBackend, db *database.Databse) JudgeBackend {
+	var nodes []string
+	for _, node := range strings.Split(os.Getenv("JUDGE_NODES"), ",") {
+		if node = strings.TrimSpace(node); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if len(nodes) == 0 {
+		fmt.Println("Judging in-process, set JUDGE_NODES to use judge nodes")
+		return NewLocalJudge(sandbox, db)
+	}
+
+	fmt.Printf("Judging on %d judge nodes\n", len(nodes))
+	judge := NewRemoteJudge(nodes, os.Getenv("JUDGE_NODE_TOKEN"))
+	judge.Start()
+	return judge
+}
@@ -0,0 +1,298 @@
+package cppruner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// judgeHealthInterval is how often every judge node is health checked
+	judgeHealthInterval = 10 * time.Second
+	// judgeRequestTimeout bounds health checks and job submission, not the judging itself
+	judgeRequestTimeout = 5 * time.Second
+	// judgeStreamTimeout gives up on a stream that goes silent, nodes repeat the state every
+	// judgeHeartbeatInterval even while a job waits for a slot
+	judgeStreamTimeout = 5 * time.Minute
+	// judgeReattachAttempts is how often a broken stream is reopened on the same node before
+	// the job moves on, the node keeps judging it meanwhile
+	judgeReattachAttempts = 2
+)
+
+// RemoteJudge sends jobs to judge nodes running cmd/judge. A job goes to the healthy node
+// with the fewest jobs in flight and is retried on another node when one can't be reached
+// or drops the job, so each job is tried on every node at most once. Every attempt carries
+// the same job id: a node that already has the job is followed instead of judging it again.
+type RemoteJudge struct {
+	token string
+	// client follows job streams, quick is for everything that should answer right away
+	client *http.Client
+	quick  *http.Client
+	nodes  []*judgeNode
+}
+
+type judgeNode struct {
+	url string
+
+	mu      sync.Mutex
+	healthy bool
+	// inflight is the node's own count from its last health check plus what we sent since
+	inflight int
+}
+
+// NewRemoteJudge balances jobs over the judge nodes at urls, token is sent as a bearer token
+func NewRemoteJudge(urls []string, token string) *RemoteJudge {
+	j := &RemoteJudge{
+		token:  token,
+		client: &http.Client{},
+		quick:  &http.Client{Timeout: judgeRequestTimeout},
+	}
+	for _, url := range urls {
+		// Nodes count as healthy until the first check says otherwise
+		j.nodes = append(j.nodes, &judgeNode{url: strings.TrimRight(url, "/"), healthy: true})
+	}
+	return j
+}
+
+// Start checks every node right away and then in the background every judgeHealthInterval
+func (j *RemoteJudge) Start() {
+	j.checkNodes()
+	go func() {
+		for range time.Tick(judgeHealthInterval) {
+			j.checkNodes()
+		}
+	}()
+}
+
+func (j *RemoteJudge) checkNodes() {
+	var wg sync.WaitGroup
+	for _, node := range j.nodes {
+		wg.Add(1)
+		go func(node *judgeNode) {
+			defer wg.Done()
+			health, err := j.health(node)
+
+			node.mu.Lock()
+			defer node.mu.Unlock()
+			if err != nil {
+				if node.healthy {
+					fmt.Printf("Judge node %s is down: %v\n", node.url, err)
+				}
+				node.healthy = false
+				return
+			}
+			if !node.healthy {
+				fmt.Printf("Judge node %s is back\n", node.url)
+			}
+			node.healthy = true
+			node.inflight = health.Inflight
+		}(node)
+	}
+	wg.Wait()
+}
+
+func (j *RemoteJudge) health(node *judgeNode) (JudgeNodeHealth, error) {
+	var health JudgeNodeHealth
+	req, err := j.request("GET", node.url+"/health", nil)
+	if err != nil {
+		return health, err
+	}
+	resp, err := j.quick.Do(req)
+	if err != nil {
+		return health, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return health, fmt.Errorf("health check returned %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	return health, err
+}
+
+// pick takes the least loaded healthy node not tried yet, any untried node when none
+// looks healthy (the last check may be stale), nil once every node was tried
+func (j *RemoteJudge) pick(tried map[*judgeNode]bool) *judgeNode {
+	var best *judgeNode
+	bestHealthy, bestLoad := false, 0
+	for _, node := range j.nodes {
+		if tried[node] {
+			continue
+		}
+		node.mu.Lock()
+		healthy, load := node.healthy, node.inflight
+		node.mu.Unlock()
+
+		if best == nil || (healthy && !bestHealthy) || (healthy == bestHealthy && load < bestLoad) {
+			best, bestHealthy, bestLoad = node, healthy, load
+		}
+	}
+	return best
+}
+
+func (j *RemoteJudge) Judge(job JudgeJob, progress func(status SubmissionStatus, test int)) (JudgeResult, error) {
+	if job.ID == "" {
+		id, err := newSubmissionID()
+		if err != nil {
+			return JudgeResult{}, err
+		}
+		job.ID = id
+	}
+
+	tried := map[*judgeNode]bool{}
+	var lastErr error
+
+	for node := j.pick(tried); node != nil; node = j.pick(tried) {
+		tried[node] = true
+
+		node.mu.Lock()
+		node.inflight++
+		node.mu.Unlock()
+
+		state, err := j.judgeOn(node, job, progress)
+
+		node.mu.Lock()
+		node.inflight--
+		if err != nil {
+			node.healthy = false
+		}
+		node.mu.Unlock()
+
+		if err != nil {
+			fmt.Printf("Judge node %s failed job %s, retrying elsewhere: %v\n", node.url, job.ID, err)
+			lastErr = err
+			continue
+		}
+		return finalResult(state)
+	}
+
+	return JudgeResult{}, fmt.Errorf("no judge node could take the job: %v", lastErr)
+}
+
+// judgeOn submits job to node and follows its stream to the end, reopening a broken stream
+// before giving up. Errors mean the node couldn't judge the job, a judged job comes back as its final state.
+func (j *RemoteJudge) judgeOn(node *judgeNode, job JudgeJob, progress func(status SubmissionStatus, test int)) (SubmissionState, error) {
+	if err := j.submit(node, job); err != nil {
+		return SubmissionState{}, err
+	}
+
+	var state SubmissionState
+	var err error
+	for attempt := 0; attempt < judgeReattachAttempts; attempt++ {
+		if state, err = j.follow(node, job.ID, progress); err == nil {
+			return state, nil
+		}
+		fmt.Printf("Lost the stream of job %s on %s: %v\n", job.ID, node.url, err)
+	}
+	return state, err
+}
+
+// submit hands job to node. A node that already has a job with the id took it on an earlier
+// attempt, that counts as accepted so the job is never judged twice on one node.
+func (j *RemoteJudge) submit(node *judgeNode, job JudgeJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %v", err)
+	}
+	req, err := j.request("POST", node.url+"/jobs", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := j.quick.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("submit returned %s", resp.Status)
+	}
+	return nil
+}
+
+// follow reads the stream of job id on node until the job finished
+func (j *RemoteJudge) follow(node *judgeNode, id string, progress func(status SubmissionStatus, test int)) (SubmissionState, error) {
+	var state SubmissionState
+
+	req, err := j.request("GET", node.url+"/jobs/"+id+"/stream", nil)
+	if err != nil {
+		return state, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return state, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return state, fmt.Errorf("stream returned %s", resp.Status)
+	}
+
+	// A node that hangs without a word is given up on, the body is closed to unblock the reader
+	watchdog := time.AfterFunc(judgeStreamTimeout, func() { resp.Body.Close() })
+	defer watchdog.Stop()
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := json.Unmarshal(line, &state); err != nil {
+				return state, fmt.Errorf("failed to decode job state: %v", err)
+			}
+			if state.Finished() {
+				return state, nil
+			}
+			watchdog.Reset(judgeStreamTimeout)
+			if progress != nil && state.Status != StatusQueued {
+				progress(state.Status, state.CurrentTest)
+			}
+		}
+		if err == io.EOF {
+			return state, fmt.Errorf("stream ended before the job finished")
+		}
+		if err != nil {
+			return state, err
+		}
+	}
+}
+
+// finalResult turns a finished job state back into what a local judge would have returned
+func finalResult(state SubmissionState) (JudgeResult, error) {
+	if state.Status == StatusFailed {
+		return JudgeResult{}, errors.New(state.Error)
+	}
+	if state.Result == nil {
+		return JudgeResult{}, fmt.Errorf("judge node returned no result")
+	}
+
+	result := *state.Result
+	if state.Error != "" {
+		if result.Verdict == VerdictCompileError {
+			return result, &CompileError{
+				Output:      strings.TrimPrefix(state.Error, "compilation failed: "),
+				Diagnostics: result.CompileErrors,
+			}
+		}
+		return result, errors.New(state.Error)
+	}
+	return result, nil
+}
+
+func (j *RemoteJudge) request(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if j.token != "" {
+		req.Header.Set("Authorization", "Bearer "+j.token)
+	}
+	return req, nil
+}
@@ -0,0 +1,271 @@
+package cppruner
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// judgeHeartbeatInterval is how often a job stream repeats the current state when nothing
+// changed, so clients can tell a job waiting for a slot or a long test from a dead node
+const judgeHeartbeatInterval = 30 * time.Second
+
+// JudgeServer is the HTTP API of a judge node:
+//
+//	GET  /health            node load, used by clients for health checks and balancing
+//	POST /jobs              submit a JudgeJob, answers 202 with its id, 409 when a job with its id exists
+//	GET  /jobs/{id}         latest SubmissionState of a job
+//	GET  /jobs/{id}/stream  every state of a job as JSON lines, repeated every judgeHeartbeatInterval
+//	                        without a change, ends once it finished
+//
+// Every request needs "Authorization: Bearer <token>" when the server has a token.
+type JudgeServer struct {
+	judge    JudgeBackend
+	token    string
+	capacity int
+	slots    chan struct{}
+	// inflight counts the jobs queued or judged on this node
+	inflight atomic.Int64
+
+	mu   sync.Mutex
+	jobs map[string]*nodeJob
+}
+
+// JudgeNodeHealth is what GET /health reports
+type JudgeNodeHealth struct {
+	Status   string `json:"status"`
+	Capacity int    `json:"capacity"`
+	Inflight int    `json:"inflight"`
+}
+
+// nodeJob is a job on this node, changed is closed and replaced on every new state
+type nodeJob struct {
+	mu       sync.Mutex
+	state    SubmissionState
+	changed  chan struct{}
+	finished time.Time
+}
+
+// JudgeNodeConfigFromEnv reads the judge node's listen address (JUDGE_NODE_ADDR, default :9090)
+// and token (JUDGE_NODE_TOKEN)
+func JudgeNodeConfigFromEnv() (addr string, token string) {
+	addr = os.Getenv("JUDGE_NODE_ADDR")
+	if addr == "" {
+		addr = ":9090"
+	}
+	return addr, os.Getenv("JUDGE_NODE_TOKEN")
+}
+
+// NewJudgeServer serves judge over HTTP, at most capacity jobs are judged at once
+func NewJudgeServer(judge JudgeBackend, token string, capacity int) *JudgeServer {
+	s := &JudgeServer{
+		judge:    judge,
+		token:    token,
+		capacity: capacity,
+		slots:    make(chan struct{}, capacity),
+		jobs:     make(map[string]*nodeJob),
+	}
+	go s.expire()
+	return s
+}
+
+// Handler returns the node's routes
+func (s *JudgeServer) Handler() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/health", s.authorized(s.handleHealth)).Methods("GET")
+	router.HandleFunc("/jobs", s.authorized(s.handleSubmit)).Methods("POST")
+	router.HandleFunc("/jobs/{id}", s.authorized(s.handleStatus)).Methods("GET")
+	router.HandleFunc("/jobs/{id}/stream", s.authorized(s.handleStream)).Methods("GET")
+	return router
+}
+
+func (s *JudgeServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid judge token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *JudgeServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(JudgeNodeHealth{Status: "ok", Capacity: s.capacity, Inflight: int(s.inflight.Load())})
+}
+
+func (s *JudgeServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	// Code plus test cases, generous but bounded
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<20)
+
+	var job JudgeJob
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid job"})
+		return
+	}
+
+	// The client's id is kept so a job can be found on the node by its submission id
+	if job.ID == "" {
+		id, err := newSubmissionID()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		job.ID = id
+	}
+
+	nj := &nodeJob{
+		state: SubmissionState{
+			ID:         job.ID,
+			UserID:     job.UserID,
+			ProblemID:  job.ProblemID,
+			Language:   job.Language,
+			Status:     StatusQueued,
+			TotalTests: len(job.TestCases),
+			UpdatedAt:  time.Now(),
+		},
+		changed: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if _, exists := s.jobs[job.ID]; exists {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "job already exists"})
+		return
+	}
+	s.jobs[job.ID] = nj
+	s.mu.Unlock()
+
+	s.inflight.Add(1)
+	go s.run(job, nj)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"id": job.ID})
+}
+
+// run judges a job once a slot is free and records every state on the way
+func (s *JudgeServer) run(job JudgeJob, nj *nodeJob) {
+	defer s.inflight.Add(-1)
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	result, err := s.judge.Judge(job, func(status SubmissionStatus, test int) {
+		nj.update(func(state *SubmissionState) {
+			state.Status = status
+			state.CurrentTest = test
+		})
+	})
+
+	nj.update(func(state *SubmissionState) {
+		state.CurrentTest = 0
+		state.Status = StatusDone
+		state.Result = &result
+		if err != nil {
+			state.Error = err.Error()
+			// A failed build is a result, anything else means the job couldn't be judged
+			if result.Verdict != VerdictCompileError {
+				state.Status = StatusFailed
+				state.Result = nil
+			}
+		}
+	})
+}
+
+func (nj *nodeJob) update(fn func(state *SubmissionState)) {
+	nj.mu.Lock()
+	defer nj.mu.Unlock()
+	fn(&nj.state)
+	nj.state.UpdatedAt = time.Now()
+	if nj.state.Finished() {
+		nj.finished = nj.state.UpdatedAt
+	}
+	close(nj.changed)
+	nj.changed = make(chan struct{})
+}
+
+// snapshot returns the current state and a channel closed on the next change
+func (nj *nodeJob) snapshot() (SubmissionState, <-chan struct{}) {
+	nj.mu.Lock()
+	defer nj.mu.Unlock()
+	return nj.state, nj.changed
+}
+
+func (s *JudgeServer) lookup(w http.ResponseWriter, r *http.Request) *nodeJob {
+	s.mu.Lock()
+	nj := s.jobs[mux.Vars(r)["id"]]
+	s.mu.Unlock()
+
+	if nj == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "job not found"})
+	}
+	return nj
+}
+
+func (s *JudgeServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	nj := s.lookup(w, r)
+	if nj == nil {
+		return
+	}
+	state, _ := nj.snapshot()
+	json.NewEncoder(w).Encode(state)
+}
+
+func (s *JudgeServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	nj := s.lookup(w, r)
+	if nj == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	heartbeat := time.NewTicker(judgeHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		state, changed := nj.snapshot()
+		if err := encoder.Encode(state); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if state.Finished() {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// expire forgets finished jobs once they can no longer be polled
+func (s *JudgeServer) expire() {
+	for range time.Tick(time.Minute) {
+		s.mu.Lock()
+		for id, nj := range s.jobs {
+			nj.mu.Lock()
+			done := !nj.finished.IsZero() && time.Since(nj.finished) > submissionStatusTTL
+			nj.mu.Unlock()
+			if done {
+				delete(s.jobs, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
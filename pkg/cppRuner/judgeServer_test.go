@@ -0,0 +1,52 @@
+package cppruner
+
+import (
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// blockingJudge accepts every job once release is closed, counting the jobs it judged
+type blockingJudge struct {
+	release chan struct{}
+	judged  atomic.Int64
+}
+
+func (j *blockingJudge) Judge(job JudgeJob, progress func(status SubmissionStatus, test int)) (JudgeResult, error) {
+	<-j.release
+	j.judged.Add(1)
+	return JudgeResult{Verdict: VerdictAccepted}, nil
+}
+
+func TestRemoteJudgeRetryIsIdempotent(t *testing.T) {
+	backend := &blockingJudge{release: make(chan struct{})}
+	node := httptest.NewServer(NewJudgeServer(backend, "secret", 1).Handler())
+	defer node.Close()
+
+	judge := NewRemoteJudge([]string{node.URL}, "secret")
+	job := JudgeJob{ID: "job-1", ProblemID: 1, Language: "cpp", Code: "int main() {}"}
+
+	// The second attempt finds the job on the node and follows it instead of judging it again
+	var wg sync.WaitGroup
+	results := make([]JudgeResult, 2)
+	errs := make([]error, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = judge.Judge(job, nil)
+		}(i)
+	}
+	close(backend.release)
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil || results[i].Verdict != VerdictAccepted {
+			t.Errorf("attempt %d = %v, %v, want AC", i+1, results[i].Verdict, errs[i])
+		}
+	}
+	if got := backend.judged.Load(); got != 1 {
+		t.Errorf("job judged %d times, want once", got)
+	}
+}
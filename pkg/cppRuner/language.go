@@ -0,0 +1,175 @@
+package cppruner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// DefaultLanguage is used when a submission does not say which language it is written in
+const DefaultLanguage = "cpp"
+
+// Language describes how a submission in one programming language is built and run
+type Language interface {
+	// Name is the identifier clients send in the "language" field
+	Name() string
+	// SourceFile is the file name the assembled source is written to
+	SourceFile() string
+	// Image is the docker image that holds the toolchain
+	Image() string
+	// CompileCommand builds the source, empty for interpreted languages
+	CompileCommand() []string
+	// RunCommand executes the built program
+	RunCommand() []string
+	// TimeMultiplier scales the problem's time limit for slower runtimes
+	TimeMultiplier() float64
+	// MemoryMultiplier scales the problem's memory limit for runtimes with a large baseline
+	MemoryMultiplier() float64
+}
+
+type languageSpec struct {
+	name       string
+	sourceFile string
+	image      string
+	compileCmd []string
+	runCmd     []string
+	// timeFactor and memoryFactor default to 1 when left at 0
+	timeFactor   float64
+	memoryFactor float64
+}
+
+func (l languageSpec) Name() string             { return l.name }
+func (l languageSpec) SourceFile() string       { return l.sourceFile }
+func (l languageSpec) Image() string            { return l.image }
+func (l languageSpec) CompileCommand() []string { return l.compileCmd }
+func (l languageSpec) RunCommand() []string     { return l.runCmd }
+
+func (l languageSpec) TimeMultiplier() float64 {
+	if l.timeFactor <= 0 {
+		return 1
+	}
+	return l.timeFactor
+}
+
+func (l languageSpec) MemoryMultiplier() float64 {
+	if l.memoryFactor <= 0 {
+		return 1
+	}
+	return l.memoryFactor
+}
+
+var languages = map[string]Language{
+	"cpp": languageSpec{
+		name:       "cpp",
+		sourceFile: "submission.cpp",
+		image:      "gcc:latest",
+		compileCmd: []string{"g++", "-o", "submission.out", "submission.cpp", "-std=c++17"},
+		runCmd:     []string{"./submission.out"},
+	},
+	"c": languageSpec{
+		name:       "c",
+		sourceFile: "submission.c",
+		image:      "gcc:latest",
+		compileCmd: []string{"gcc", "-o", "submission.out", "submission.c", "-std=c11", "-lm"},
+		runCmd:     []string{"./submission.out"},
+	},
+	"python3": languageSpec{
+		name:         "python3",
+		sourceFile:   "submission.py",
+		image:        "python:3.12-slim",
+		runCmd:       []string{"python3", "submission.py"},
+		timeFactor:   3,
+		memoryFactor: 2,
+	},
+	"go": languageSpec{
+		name:       "go",
+		sourceFile: "main.go",
+		image:      "golang:1.21-alpine",
+		compileCmd: []string{"go", "build", "-o", "submission.out", "main.go"},
+		runCmd:     []string{"./submission.out"},
+		timeFactor: 1.5,
+	},
+	"java": languageSpec{
+		name:         "java",
+		sourceFile:   "Main.java",
+		image:        "eclipse-temurin:17",
+		compileCmd:   []string{"javac", "Main.java"},
+		runCmd:       []string{"java", "-cp", ".", "Main"},
+		timeFactor:   2,
+		memoryFactor: 2,
+	},
+	"rust": languageSpec{
+		name:       "rust",
+		sourceFile: "main.rs",
+		image:      "rust:latest",
+		compileCmd: []string{"rustc", "-O", "-o", "submission.out", "main.rs"},
+		runCmd:     []string{"./submission.out"},
+	},
+	"javascript": languageSpec{
+		name:         "javascript",
+		sourceFile:   "main.js",
+		image:        "node:20-slim",
+		runCmd:       []string{"node", "main.js"},
+		timeFactor:   2,
+		memoryFactor: 2,
+	},
+}
+
+// GetLanguage looks up a registered language, an empty name means DefaultLanguage
+func GetLanguage(name string) (Language, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DefaultLanguage
+	}
+
+	lang, ok := languages[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", name)
+	}
+	return lang, nil
+}
+
+// RegisterLanguage adds or replaces a language in the registry
+func RegisterLanguage(lang Language) {
+	languages[lang.Name()] = lang
+}
+
+// SupportedLanguages returns the names of all registered languages in sorted order
+func SupportedLanguages() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templateFor picks the header and main wrapped around the user code.
+// A LanguageTemplate row wins, the legacy problem columns are the C++ template,
+// and any other language without a template is judged as a full program.
+func templateFor(problem *modles.ProblemPropaty, lang Language) (string, string) {
+	for _, t := range problem.Templates {
+		if t.Language == lang.Name() {
+			return t.HaderFile, t.MainFunc
+		}
+	}
+
+	if lang.Name() == DefaultLanguage {
+		return problem.HaderFile, problem.MainFunc
+	}
+	return "", ""
+}
+
+// StubsFor returns the starter code shown to the player for every language the problem has a template for
+func StubsFor(problem *modles.ProblemPropaty) map[string]string {
+	stubs := map[string]string{}
+	if problem.FuncBody != "" {
+		stubs[DefaultLanguage] = problem.FuncBody
+	}
+	for _, t := range problem.Templates {
+		stubs[t.Language] = t.FuncBody
+	}
+	return stubs
+}
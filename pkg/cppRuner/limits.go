@@ -0,0 +1,129 @@
+package cppruner
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// Limits are the resources one run of a submission may use
+type Limits struct {
+	TimeMs   int64 `json:"time_limit_ms"`
+	MemoryMB int64 `json:"memory_limit_mb"`
+	OutputKB int64 `json:"output_limit_kb"`
+	StackMB  int64 `json:"stack_limit_mb"`
+}
+
+// Time is the wall clock limit of one test case
+func (l Limits) Time() time.Duration {
+	return time.Duration(l.TimeMs) * time.Millisecond
+}
+
+// killAfter is when the judge gives up on a sandbox that ignores the in-container timeout
+func (l Limits) killAfter() time.Duration {
+	return 3*l.Time() + 5*time.Second
+}
+
+// JudgeSettings are the server wide defaults, problems override Limits column by column
+type JudgeSettings struct {
+	Limits Limits
+	// CPUs is the docker CPU quota a test case runs with
+	CPUs float64
+	// CompileTimeout bounds every build, submissions and helper programs alike
+	CompileTimeout time.Duration
+	// PidsLimit caps the processes and threads of one test case, hitting it is a security violation
+	PidsLimit int64
+	// TestParallelism is how many test cases of one submission may run at the same time
+	TestParallelism int64
+	// RunSlots bounds the compiles and runs in flight across all submissions of this process
+	RunSlots int64
+}
+
+var (
+	judgeSettings     JudgeSettings
+	judgeSettingsOnce sync.Once
+)
+
+// DefaultJudgeSettings returns the settings read once by JudgeSettingsFromEnv
+func DefaultJudgeSettings() JudgeSettings {
+	judgeSettingsOnce.Do(func() {
+		judgeSettings = JudgeSettingsFromEnv()
+	})
+	return judgeSettings
+}
+
+// JudgeSettingsFromEnv reads JUDGE_TIME_LIMIT_MS, JUDGE_MEMORY_LIMIT_MB, JUDGE_OUTPUT_LIMIT_KB,
+// JUDGE_STACK_LIMIT_MB, JUDGE_RUN_CPUS, JUDGE_COMPILE_TIMEOUT, JUDGE_PIDS_LIMIT,
+// JUDGE_TEST_PARALLELISM and JUDGE_RUN_SLOTS
+func JudgeSettingsFromEnv() JudgeSettings {
+	s := JudgeSettings{
+		Limits: Limits{
+			TimeMs:   2000,
+			MemoryMB: 128,
+			OutputKB: 64 * 1024,
+			StackMB:  64,
+		},
+		CPUs:            0.5,
+		CompileTimeout:  30 * time.Second,
+		PidsLimit:       64,
+		TestParallelism: 2,
+		RunSlots:        int64(runtime.NumCPU()),
+	}
+
+	envInt64("JUDGE_TIME_LIMIT_MS", &s.Limits.TimeMs)
+	envInt64("JUDGE_MEMORY_LIMIT_MB", &s.Limits.MemoryMB)
+	envInt64("JUDGE_OUTPUT_LIMIT_KB", &s.Limits.OutputKB)
+	envInt64("JUDGE_STACK_LIMIT_MB", &s.Limits.StackMB)
+	envInt64("JUDGE_PIDS_LIMIT", &s.PidsLimit)
+	envInt64("JUDGE_TEST_PARALLELISM", &s.TestParallelism)
+	envInt64("JUDGE_RUN_SLOTS", &s.RunSlots)
+
+	if v := os.Getenv("JUDGE_RUN_CPUS"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			s.CPUs = f
+		}
+	}
+	if v := os.Getenv("JUDGE_COMPILE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			s.CompileTimeout = d
+		}
+	}
+
+	return s
+}
+
+func envInt64(name string, target *int64) {
+	if n, err := strconv.ParseInt(os.Getenv(name), 10, 64); err == nil && n > 0 {
+		*target = n
+	}
+}
+
+// ProblemLimits is the problem's own limits with server defaults filled in, before any language scaling
+func ProblemLimits(problem *modles.ProblemPropaty) Limits {
+	l := DefaultJudgeSettings().Limits
+	if problem.TimeLimitMs > 0 {
+		l.TimeMs = problem.TimeLimitMs
+	}
+	if problem.MemoryLimitMB > 0 {
+		l.MemoryMB = problem.MemoryLimitMB
+	}
+	if problem.OutputLimitKB > 0 {
+		l.OutputKB = problem.OutputLimitKB
+	}
+	if problem.StackLimitMB > 0 {
+		l.StackMB = problem.StackLimitMB
+	}
+	return l
+}
+
+// limitsFor is what a submission in lang actually runs with
+func limitsFor(problem *modles.ProblemPropaty, lang Language) Limits {
+	l := ProblemLimits(problem)
+	l.TimeMs = int64(float64(l.TimeMs) * lang.TimeMultiplier())
+	l.MemoryMB = int64(float64(l.MemoryMB) * lang.MemoryMultiplier())
+	return l
+}
@@ -0,0 +1,29 @@
+package cppruner
+
+import (
+	"os"
+	"strings"
+)
+
+// LocalConfig controls the local sandbox, which runs programs with the host's
+// toolchains instead of docker so the judge can be exercised anywhere
+type LocalConfig struct {
+	// Namespaces runs programs in fresh user, network, IPC and UTS namespaces
+	Namespaces bool
+	// CgroupParent is a delegated cgroup v2 directory, every run gets a child cgroup with
+	// the memory, CPU and process limits. Without it memory is only capped by ulimit -v and not measured.
+	CgroupParent string
+}
+
+// LocalConfigFromEnv reads JUDGE_LOCAL_NAMESPACES (on unless "0" or "false") and JUDGE_LOCAL_CGROUP
+func LocalConfigFromEnv() LocalConfig {
+	cfg := LocalConfig{
+		Namespaces:   true,
+		CgroupParent: os.Getenv("JUDGE_LOCAL_CGROUP"),
+	}
+	switch strings.ToLower(os.Getenv("JUDGE_LOCAL_NAMESPACES")) {
+	case "0", "false":
+		cfg.Namespaces = false
+	}
+	return cfg
+}
@@ -0,0 +1,210 @@
+//go:build linux
+
+package cppruner
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// localBackend runs submissions straight on the host with os/exec: namespaces cut off
+// the network, rlimits and an optional cgroup bound the resources. The toolchain image
+// is ignored, compilers and interpreters must be installed on the host.
+// There is no seccomp profile or read-only mount here, programs run as the judge's own
+// user; use the docker backend for anything facing untrusted users.
+type localBackend struct {
+	cfg LocalConfig
+}
+
+func NewLocalSandbox(cfg LocalConfig) (SandboxBackend, error) {
+	if cfg.CgroupParent != "" {
+		if _, err := os.Stat(filepath.Join(cfg.CgroupParent, "cgroup.controllers")); err != nil {
+			return nil, fmt.Errorf("%s is not a cgroup v2 directory: %v", cfg.CgroupParent, err)
+		}
+		// Hand the memory, cpu and pids controllers down to the per run cgroups, fails harmlessly if already done
+		os.WriteFile(filepath.Join(cfg.CgroupParent, "cgroup.subtree_control"), []byte("+memory +cpu +pids"), 0644)
+	}
+
+	fmt.Println("Judging with the local sandbox, toolchains come from the host")
+	return &localBackend{cfg: cfg}, nil
+}
+
+func (b *localBackend) NewSandbox(image, dir string) (Sandbox, error) {
+	scratch, err := newInputDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(filepath.Join(scratch, "tmp"), 0700); err != nil {
+		os.RemoveAll(scratch)
+		return nil, fmt.Errorf("failed to create sandbox tmp directory: %v", err)
+	}
+	return &localSandbox{cfg: b.cfg, dir: dir, scratch: scratch}, nil
+}
+
+type localSandbox struct {
+	cfg LocalConfig
+	dir string
+	// scratch keeps input.txt and the program's TMPDIR outside the work directory
+	scratch string
+}
+
+func (s *localSandbox) Compile(compileCmd []string) ([]byte, error) {
+	timeout := DefaultJudgeSettings().CompileTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, compileCmd[0], compileCmd[1:]...)
+	cmd.Dir = s.dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	killGroupOnCancel(cmd)
+
+	compileOutput, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return compileOutput, fmt.Errorf("compilation timed out after %v", timeout)
+		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return compileOutput, fmt.Errorf("failed to run %s: %v", compileCmd[0], err)
+		}
+		fmt.Printf("Compile output: %s\n", string(compileOutput))
+		return compileOutput, &CompileError{Output: string(compileOutput)}
+	}
+	return compileOutput, nil
+}
+
+func (s *localSandbox) WriteInput(input string) error {
+	return writeInputFile(s.scratch, input)
+}
+
+func (s *localSandbox) Run(ctx context.Context, script string, stdin string, limits Limits) ([]byte, error) {
+	cmd, done, err := s.command(ctx, script, limits)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+	return out.Bytes(), err
+}
+
+func (s *localSandbox) Pipe(ctx context.Context, script string, stdin io.Reader, stdout io.WriteCloser, limits Limits) ([]byte, error) {
+	cmd, done, err := s.command(ctx, script, limits)
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+	defer done()
+	return runPiped(cmd, stdin, stdout)
+}
+
+// command prepares the shell running script, done is called once it exited: nothing the
+// script started may outlive it and its cgroup goes away
+func (s *localSandbox) command(ctx context.Context, script string, limits Limits) (*exec.Cmd, func(), error) {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if s.cfg.Namespaces {
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+
+	// runScript reads usage from $JUDGE_CGROUP, point it nowhere rather than at the host's cgroup
+	cgroup := "/nonexistent"
+	rlimits := fmt.Sprintf("ulimit -t %d\n", int(math.Ceil(limits.Time().Seconds()))+1)
+
+	cleanup := func() {}
+	if s.cfg.CgroupParent != "" {
+		dir, fd, err := s.newCgroup(limits)
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() {
+			syscall.Close(fd)
+			os.Remove(dir)
+		}
+
+		cgroup = dir
+		attr.UseCgroupFD = true
+		attr.CgroupFD = fd
+	} else {
+		rlimits += fmt.Sprintf("ulimit -v %d\n", limits.MemoryMB*1024)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", rlimits+script)
+	cmd.Dir = s.dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + s.dir,
+		"TMPDIR=" + filepath.Join(s.scratch, "tmp"),
+		"JUDGE_CGROUP=" + cgroup,
+		"JUDGE_INPUT=" + filepath.Join(s.scratch, "input.txt"),
+	}
+	cmd.SysProcAttr = attr
+	killGroupOnCancel(cmd)
+
+	done := func() {
+		if cmd.Process != nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		cleanup()
+	}
+	return cmd, done, nil
+}
+
+func (s *localSandbox) Cleanup() {
+	os.RemoveAll(s.scratch)
+}
+
+// newCgroup creates a child cgroup with the run limits and returns it opened for CLONE_INTO_CGROUP
+func (s *localSandbox) newCgroup(limits Limits) (string, int, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", 0, fmt.Errorf("failed to name run cgroup: %v", err)
+	}
+	dir := filepath.Join(s.cfg.CgroupParent, "run_"+hex.EncodeToString(b))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create run cgroup: %v", err)
+	}
+
+	settings := map[string]string{
+		"memory.max":      fmt.Sprintf("%d", limits.MemoryMB*1024*1024),
+		"memory.swap.max": "0",
+		"cpu.max":         fmt.Sprintf("%d 100000", int64(DefaultJudgeSettings().CPUs*100000)),
+		"pids.max":        fmt.Sprintf("%d", DefaultJudgeSettings().PidsLimit),
+	}
+	for file, value := range settings {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+			os.Remove(dir)
+			return "", 0, fmt.Errorf("failed to set %s: %v", file, err)
+		}
+	}
+
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		os.Remove(dir)
+		return "", 0, fmt.Errorf("failed to open run cgroup: %v", err)
+	}
+	return dir, fd, nil
+}
+
+// killGroupOnCancel makes a context cancel kill the whole process group, not just its leader
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package cppruner
+
+import "fmt"
+
+// NewLocalSandbox needs Linux namespaces and rlimits
+func NewLocalSandbox(cfg LocalConfig) (SandboxBackend, error) {
+	return nil, fmt.Errorf("the local sandbox is only supported on linux")
+}
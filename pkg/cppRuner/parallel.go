@@ -0,0 +1,200 @@
+package cppruner
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// StopPolicy decides whether judging goes on after a test case fails
+type StopPolicy string
+
+const (
+	// StopOnFirstFailure skips the remaining tests once one fails, battles only need the verdict
+	StopOnFirstFailure StopPolicy = "first_failure"
+	// RunAll judges every test case, practice feedback and scoring need all of them
+	RunAll StopPolicy = "all"
+)
+
+var (
+	runSlots     chan struct{}
+	runSlotsOnce sync.Once
+)
+
+// acquireRunSlot blocks until one of the process wide RunSlots is free. Every compile and
+// every test run holds a slot, so parallel tests of one submission queue up behind the
+// work of others instead of starving them.
+func acquireRunSlot() {
+	runSlotsOnce.Do(func() {
+		runSlots = make(chan struct{}, DefaultJudgeSettings().RunSlots)
+	})
+	runSlots <- struct{}{}
+}
+
+func releaseRunSlot() {
+	<-runSlots
+}
+
+// testLane is one sandbox working through test cases, each lane has its own copy of
+// the work directory so runs never share output files
+type testLane struct {
+	box   Sandbox
+	dir   string
+	check checkFunc
+	// helpers runs the custom checker and the interactor from its own copy of the helper
+	// directory, a sandbox the submission never runs in. Nil when the problem has neither.
+	helpers    Sandbox
+	helpersDir string
+}
+
+// runTests judges the task's test cases on up to TestParallelism lanes. Tests are started
+// in order, so with StopOnFirstFailure every test before the first failure still runs and
+// the verdict is the same as judging one by one. Tests never started come back as skipped.
+// helperDir holds the compiled checker and interactor, "" when there are none.
+func (t *judgeTask) runTests(box Sandbox, helperDir string) ([]TestResult, error) {
+	first := &testLane{box: box, dir: t.dir}
+	if err := t.openHelpers(first, helperDir); err != nil {
+		return nil, err
+	}
+	defer first.closeHelpers()
+	lanes := []*testLane{first}
+
+	parallelism := int(DefaultJudgeSettings().TestParallelism)
+	for len(lanes) < parallelism && len(lanes) < len(t.testCases) {
+		lane, err := t.newLane(helperDir)
+		if err != nil {
+			// Fewer lanes only make judging slower
+			fmt.Printf("Failed to add a test lane: %v\n", err)
+			break
+		}
+		defer lane.cleanup()
+		lanes = append(lanes, lane)
+	}
+
+	results := make([]TestResult, len(t.testCases))
+	started := make([]bool, len(t.testCases))
+	var (
+		mu        sync.Mutex
+		next      int
+		firstFail = len(t.testCases)
+		runErr    error
+		wg        sync.WaitGroup
+	)
+
+	claim := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if runErr != nil || next >= len(t.testCases) || (t.stop == StopOnFirstFailure && next > firstFail) {
+			return 0, false
+		}
+		i := next
+		next++
+		started[i] = true
+		t.progress.report(StatusRunning, i+1)
+		return i, true
+	}
+
+	fmt.Printf("Running %d test cases on %d lanes...\n", len(t.testCases), len(lanes))
+	for _, lane := range lanes {
+		wg.Add(1)
+		go func(lane *testLane) {
+			defer wg.Done()
+			for {
+				i, ok := claim()
+				if !ok {
+					return
+				}
+				result, err := t.runTest(lane, i)
+
+				mu.Lock()
+				if err != nil && runErr == nil {
+					runErr = err
+				}
+				results[i] = result
+				if result.Verdict != VerdictAccepted && result.Verdict != VerdictOK && i < firstFail {
+					firstFail = i
+				}
+				mu.Unlock()
+			}
+		}(lane)
+	}
+	wg.Wait()
+
+	if runErr != nil {
+		return nil, runErr
+	}
+
+	for i, tc := range t.testCases {
+		if !started[i] {
+			results[i] = TestResult{Case: i + 1, Hidden: tc.Hidden, Verdict: VerdictSkipped}
+		}
+	}
+	return results, nil
+}
+
+// newLane copies the built work directory and opens another sandbox over the copy
+func (t *judgeTask) newLane(helperDir string) (*testLane, error) {
+	dir, err := os.MkdirTemp("", "submission_lane_*")
+	if err != nil {
+		return nil, err
+	}
+	if err := copyFiles(t.dir, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	box, err := t.sandbox.NewSandbox(t.lang.Image(), dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	lane := &testLane{box: box, dir: dir}
+	if err := t.openHelpers(lane, helperDir); err != nil {
+		lane.cleanup()
+		return nil, err
+	}
+	return lane, nil
+}
+
+// openHelpers gives lane its helper box over a copy of helperDir and its check function
+func (t *judgeTask) openHelpers(lane *testLane, helperDir string) error {
+	if helperDir != "" {
+		dir, err := os.MkdirTemp("", "helpers_lane_*")
+		if err != nil {
+			return err
+		}
+		if err := copyFiles(helperDir, dir); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+		box, err := t.sandbox.NewSandbox(checkerImage, dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+		lane.helpers, lane.helpersDir = box, dir
+	}
+
+	check, err := t.checkFunc(lane)
+	if err != nil {
+		lane.closeHelpers()
+		return err
+	}
+	lane.check = check
+	return nil
+}
+
+func (l *testLane) closeHelpers() {
+	if l.helpers != nil {
+		l.helpers.Cleanup()
+		os.RemoveAll(l.helpersDir)
+		l.helpers = nil
+	}
+}
+
+// cleanup releases a lane made by newLane, the first lane's box belongs to the task
+func (l *testLane) cleanup() {
+	l.closeHelpers()
+	l.box.Cleanup()
+	os.RemoveAll(l.dir)
+}
@@ -0,0 +1,699 @@
+package cppruner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sandboxLabel marks every container started by the pool so leftovers can be reaped on startup
+const sandboxLabel = "codewar.sandbox=1"
+
+// PoolConfig controls how many warm sandboxes are kept and for how long
+type PoolConfig struct {
+	// Size is the number of warm containers kept ready per toolchain image, 0 disables the pool
+	Size int
+	// IdleTimeout evicts the warm containers of an image nobody has judged with for this long
+	IdleTimeout time.Duration
+	// HealthInterval is how often idle containers are checked and the pool is refilled
+	HealthInterval time.Duration
+}
+
+// PoolConfigFromEnv reads JUDGE_POOL_SIZE, JUDGE_POOL_IDLE_TIMEOUT and JUDGE_POOL_HEALTH_INTERVAL
+func PoolConfigFromEnv() PoolConfig {
+	cfg := PoolConfig{
+		Size:           2,
+		IdleTimeout:    10 * time.Minute,
+		HealthInterval: 30 * time.Second,
+	}
+
+	if v := os.Getenv("JUDGE_POOL_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.Size = n
+		}
+	}
+	if v := os.Getenv("JUDGE_POOL_IDLE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.IdleTimeout = d
+		}
+	}
+	if v := os.Getenv("JUDGE_POOL_HEALTH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.HealthInterval = d
+		}
+	}
+
+	return cfg
+}
+
+// boxSizeMB caps the tmpfs holding a warm container's work directory
+const boxSizeMB = 256
+
+// warmContainer is a pre-started, network-less container idling in /box. It runs as boxOwner,
+// which owns /box, and everything a submission or problem setter wrote is exec'd as sandboxUser.
+type warmContainer struct {
+	id    string
+	image string
+	// inputDir is the host side of the container's read-only /input mount
+	inputDir string
+	// memoryCgroup is the host path of the container's memory cgroup, found on first use
+	memoryCgroup string
+}
+
+// SandboxPool keeps pre-started containers per image so a submission only pays for
+// docker exec calls instead of a full docker run per test case. A container serves a
+// single submission and is thrown away afterwards, so nothing leaks between users.
+// Between the tests of that submission every process of sandboxUser is killed and
+// everything it could write is wiped, so one test can't touch the next.
+type SandboxPool struct {
+	cfg      PoolConfig
+	mu       sync.Mutex
+	warm     map[string][]*warmContainer
+	lastUsed map[string]time.Time
+	stop     chan struct{}
+}
+
+var (
+	defaultPool     *SandboxPool
+	defaultPoolOnce sync.Once
+)
+
+// DefaultPool returns the process wide pool configured from the environment, nil when disabled
+func DefaultPool() *SandboxPool {
+	defaultPoolOnce.Do(func() {
+		cfg := PoolConfigFromEnv()
+		if cfg.Size == 0 {
+			fmt.Println("Judge sandbox pool disabled, using one docker run per step")
+			return
+		}
+		if err := checkDocker(); err != nil {
+			fmt.Printf("Judge sandbox pool disabled: %v\n", err)
+			return
+		}
+		defaultPool = NewSandboxPool(cfg)
+	})
+	return defaultPool
+}
+
+func NewSandboxPool(cfg PoolConfig) *SandboxPool {
+	p := &SandboxPool{
+		cfg:      cfg,
+		warm:     make(map[string][]*warmContainer),
+		lastUsed: make(map[string]time.Time),
+		stop:     make(chan struct{}),
+	}
+
+	p.reapLeftovers()
+	go p.maintain()
+
+	return p
+}
+
+// Acquire hands out a warm container for image, starting one on the spot if none is ready
+func (p *SandboxPool) Acquire(image string) (*warmContainer, error) {
+	p.mu.Lock()
+	p.lastUsed[image] = time.Now()
+	var c *warmContainer
+	if idle := p.warm[image]; len(idle) > 0 {
+		c = idle[len(idle)-1]
+		p.warm[image] = idle[:len(idle)-1]
+	}
+	p.mu.Unlock()
+
+	// Top the pool back up without making this submission wait for it
+	go p.refill(image)
+
+	if c != nil {
+		return c, nil
+	}
+
+	fmt.Printf("No warm sandbox for %s, starting one\n", image)
+	return p.start(image)
+}
+
+// Discard removes a container once its submission is done
+func (p *SandboxPool) Discard(c *warmContainer) {
+	go c.remove()
+}
+
+// Close stops the maintenance loop and removes all idle containers
+func (p *SandboxPool) Close() {
+	close(p.stop)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for image, idle := range p.warm {
+		for _, c := range idle {
+			c.remove()
+		}
+		delete(p.warm, image)
+	}
+}
+
+func (p *SandboxPool) start(image string) (*warmContainer, error) {
+	if err := ensureImage(image); err != nil {
+		return nil, err
+	}
+
+	security, err := securityArgs(boxOwner, compilePidsLimit)
+	if err != nil {
+		return nil, err
+	}
+	inputDir, err := newInputDir()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	args := []string{"run", "-d", "--rm", "--init",
+		"--label", sandboxLabel,
+		"--network=none", // No network access
+		"--memory=512m",  // Compile limits, lowered with docker update before running tests
+		"--memory-swap=512m",
+		"--cpus=1.0",
+		"--tmpfs", fmt.Sprintf("/box:rw,exec,nosuid,size=%dm,mode=0755", boxSizeMB),
+		"-v", fmt.Sprintf("%s:/input:ro", inputDir),
+		"-w", "/box",
+		"--entrypoint", "sh",
+	}
+	args = append(args, security...)
+	args = append(args, image, "-c", "while true; do sleep 3600; done")
+
+	out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(inputDir)
+		return nil, fmt.Errorf("failed to start sandbox for %s: %v: %s", image, err, strings.TrimSpace(string(out)))
+	}
+
+	return &warmContainer{id: strings.TrimSpace(string(out)), image: image, inputDir: inputDir}, nil
+}
+
+func (p *SandboxPool) refill(image string) {
+	p.mu.Lock()
+	missing := p.cfg.Size - len(p.warm[image])
+	p.mu.Unlock()
+
+	for i := 0; i < missing; i++ {
+		c, err := p.start(image)
+		if err != nil {
+			fmt.Printf("Failed to refill sandbox pool: %v\n", err)
+			return
+		}
+
+		p.mu.Lock()
+		if len(p.warm[image]) >= p.cfg.Size {
+			p.mu.Unlock()
+			c.remove()
+			return
+		}
+		p.warm[image] = append(p.warm[image], c)
+		p.mu.Unlock()
+	}
+}
+
+// maintain evicts idle images, drops dead containers and refills the pool
+func (p *SandboxPool) maintain() {
+	ticker := time.NewTicker(p.cfg.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		var evicted, check []*warmContainer
+		for image, idle := range p.warm {
+			if time.Since(p.lastUsed[image]) > p.cfg.IdleTimeout {
+				evicted = append(evicted, idle...)
+				delete(p.warm, image)
+				delete(p.lastUsed, image)
+				continue
+			}
+			check = append(check, idle...)
+		}
+		p.mu.Unlock()
+
+		for _, c := range evicted {
+			fmt.Printf("Evicting idle sandbox for %s\n", c.image)
+			c.remove()
+		}
+
+		for _, c := range check {
+			if isRunning(c.id) {
+				continue
+			}
+			fmt.Printf("Sandbox %s for %s is unhealthy, replacing it\n", c.id, c.image)
+			p.mu.Lock()
+			idle := p.warm[c.image]
+			for i, w := range idle {
+				if w == c {
+					p.warm[c.image] = append(idle[:i], idle[i+1:]...)
+					break
+				}
+			}
+			p.mu.Unlock()
+			c.remove()
+		}
+
+		p.mu.Lock()
+		var images []string
+		for image := range p.lastUsed {
+			images = append(images, image)
+		}
+		p.mu.Unlock()
+
+		for _, image := range images {
+			p.refill(image)
+		}
+	}
+}
+
+func (p *SandboxPool) reapLeftovers() {
+	out, err := exec.Command("docker", "ps", "-aq", "--filter", "label="+sandboxLabel).Output()
+	if err != nil {
+		return
+	}
+	for _, id := range strings.Fields(string(out)) {
+		removeContainer(id)
+	}
+}
+
+// NewSandbox hands out a pool backed sandbox: every build gets a fresh warm container and
+// all runs share a second one whose limits were lowered to the run limits
+func (p *SandboxPool) NewSandbox(image, dir string) (Sandbox, error) {
+	return &poolSandbox{pool: p, image: image, dir: dir}, nil
+}
+
+type poolSandbox struct {
+	pool  *SandboxPool
+	image string
+	dir   string
+	// box is acquired by the first Run, after every build is done, and replaced when
+	// a run leaves processes behind that can't be killed
+	box *warmContainer
+	// input is the latest WriteInput, a new box gets it too
+	input *string
+}
+
+func (s *poolSandbox) Compile(compileCmd []string) ([]byte, error) {
+	return s.pool.compile(s.image, s.dir, compileCmd)
+}
+
+func (s *poolSandbox) WriteInput(input string) error {
+	s.input = &input
+	if s.box == nil {
+		return nil
+	}
+	return writeInputFile(s.box.inputDir, input)
+}
+
+func (s *poolSandbox) Run(ctx context.Context, script string, stdin string, limits Limits) ([]byte, error) {
+	return s.run(ctx, script, limits, func(cmd *exec.Cmd) ([]byte, error) {
+		cmd.Stdin = strings.NewReader(stdin)
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		err := cmd.Run()
+		return out.Bytes(), err
+	})
+}
+
+func (s *poolSandbox) Pipe(ctx context.Context, script string, stdin io.Reader, stdout io.WriteCloser, limits Limits) ([]byte, error) {
+	return s.run(ctx, script, limits, func(cmd *exec.Cmd) ([]byte, error) {
+		return runPiped(cmd, stdin, stdout)
+	})
+}
+
+// boxResetScript runs as sandboxUser ahead of every script: whatever an earlier run left
+// in the only places it could write is gone before the next one starts
+const boxResetScript = "chmod -R u+rwX /box/out /tmp 2>/dev/null; find /box/out /tmp -mindepth 1 -delete 2>/dev/null\n"
+
+// run executes script as sandboxUser through runCmd, then kills every process it left behind
+// before its results are copied out, so nothing can change them afterwards
+func (s *poolSandbox) run(ctx context.Context, script string, limits Limits, runCmd func(cmd *exec.Cmd) ([]byte, error)) ([]byte, error) {
+	if err := s.prepare(limits); err != nil {
+		return nil, err
+	}
+	box := s.box
+
+	// The container's peak memory covers every earlier run too, this run starts a new one
+	peak, peakErr := box.resetPeak()
+	if peak != nil {
+		defer peak.Close()
+	}
+
+	out, err := runCmd(box.command(ctx, sandboxUser, true, "sh", "-c", boxResetScript+script))
+	peakBytes, readErr := readPeak(peak)
+
+	if killErr := box.killAll(); killErr != nil {
+		// The next run gets a box nothing of this one survives in
+		s.pool.Discard(box)
+		s.box = nil
+		return out, killErr
+	}
+	if peakErr != nil {
+		// The box keeps this run's peak, so the next run gets a fresh one
+		defer s.pool.Discard(box)
+		s.box = nil
+	}
+	if err != nil {
+		return out, err
+	}
+
+	os.RemoveAll(filepath.Join(s.dir, resultDir))
+	if err := box.copyOut(resultDir, s.dir); err != nil {
+		return out, err
+	}
+	if peak != nil && readErr == nil {
+		return out, recordPeak(s.dir, peakBytes)
+	}
+	return out, nil
+}
+
+// prepare acquires the run box on first use: lowered limits, the built files installed
+// read-only and the latest input
+func (s *poolSandbox) prepare(limits Limits) error {
+	if s.box != nil {
+		return nil
+	}
+
+	box, err := s.pool.Acquire(s.image)
+	if err != nil {
+		return err
+	}
+
+	settings := DefaultJudgeSettings()
+	if err := box.limit(limits.MemoryMB, settings.CPUs, settings.PidsLimit); err != nil {
+		s.pool.Discard(box)
+		return err
+	}
+	if err := box.install(s.dir); err != nil {
+		s.pool.Discard(box)
+		return err
+	}
+	if s.input != nil {
+		if err := writeInputFile(box.inputDir, *s.input); err != nil {
+			s.pool.Discard(box)
+			return err
+		}
+	}
+	s.box = box
+	return nil
+}
+
+func (s *poolSandbox) Cleanup() {
+	if s.box != nil {
+		s.pool.Discard(s.box)
+	}
+}
+
+// compile runs a build command over dir in a fresh warm container for image and copies
+// the artifacts back into dir, a failing build comes back as *CompileError
+func (p *SandboxPool) compile(image, dir string, compileCmd []string) ([]byte, error) {
+	box, err := p.Acquire(image)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Discard(box)
+
+	if err := box.install(dir); err != nil {
+		return nil, err
+	}
+	// The build writes its artifacts next to the sources, this box is thrown away afterwards
+	if out, err := box.exec(context.Background(), boxOwner, nil, "chmod", "1777", "/box"); err != nil {
+		return nil, fmt.Errorf("failed to open up build directory: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	timeout := DefaultJudgeSettings().CompileTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	compileOutput, err := box.exec(ctx, sandboxUser, nil, compileCmd...)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return compileOutput, fmt.Errorf("compilation timed out after %v", timeout)
+		}
+		fmt.Printf("Compile output: %s\n", string(compileOutput))
+		return compileOutput, &CompileError{Output: string(compileOutput)}
+	}
+
+	if err := box.copyOut(".", dir); err != nil {
+		return compileOutput, err
+	}
+	return compileOutput, nil
+}
+
+// command is a docker exec of args in /box as user, with stdin attached when withStdin is set
+func (c *warmContainer) command(ctx context.Context, user string, withStdin bool, args ...string) *exec.Cmd {
+	dockerArgs := []string{"exec", "-w", "/box", "-u", user}
+	if withStdin {
+		dockerArgs = append(dockerArgs, "-i")
+	}
+	dockerArgs = append(dockerArgs, c.id)
+	return exec.CommandContext(ctx, "docker", append(dockerArgs, args...)...)
+}
+
+func (c *warmContainer) exec(ctx context.Context, user string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := c.command(ctx, user, stdin != nil, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.Bytes(), err
+}
+
+// killScript kills every process of sandboxUser but itself until none is left,
+// giving up on a program that forks faster than it dies
+const killScript = `n=0
+while kill -9 -1 2>/dev/null; do
+  n=$((n+1))
+  [ $n -ge 50 ] && exit 1
+  sleep 0.02
+done
+exit 0`
+
+// killAll stops everything sandboxUser runs in the container, the box itself runs as boxOwner
+func (c *warmContainer) killAll() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := c.exec(ctx, sandboxUser, nil, "sh", "-c", killScript)
+	if err != nil {
+		return fmt.Errorf("failed to stop the processes left in sandbox %s: %v: %s", c.id, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// install copies the contents of dir into /box as boxOwner: sandboxUser can read and run the
+// files but not change them, only /box/out is left writable for results
+func (c *warmContainer) install(dir string) error {
+	if err := c.copyIn(dir); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	out, err := c.exec(ctx, boxOwner, nil, "sh", "-c", "chmod -R go-w /box && mkdir -p /box/"+resultDir+" && chmod 1777 /box/"+resultDir)
+	if err != nil {
+		return fmt.Errorf("failed to install files in sandbox: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// copyIn copies the contents of dir into /box as boxOwner. The root filesystem is read-only and
+// /box is a tmpfs, which docker cp can't write to, so the files go in as a tar stream.
+func (c *warmContainer) copyIn(dir string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, dir))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Without capabilities the owner can't hand files to anyone else, they stay its own
+	out, err := c.exec(ctx, boxOwner, pr, "tar", "--no-same-owner", "-xf", "-", "-C", "/box")
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("failed to copy files into sandbox: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// copyOut copies /box/<path> from the container into dir. The archive was written by
+// the submission's user, so only plain files and directories inside dir are extracted.
+func (c *warmContainer) copyOut(path, dir string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "docker", "exec", c.id, "tar", "-cf", "-", "-C", "/box", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to copy files out of sandbox: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to copy files out of sandbox: %v", err)
+	}
+
+	extractErr := extractTar(stdout, dir, boxSizeMB*1024*1024)
+	if extractErr != nil {
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	if extractErr != nil {
+		return fmt.Errorf("failed to copy files out of sandbox: %v", extractErr)
+	}
+	if waitErr != nil {
+		return fmt.Errorf("failed to copy files out of sandbox: %v: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// limit lowers the container's memory, CPU and process caps to the run limits
+func (c *warmContainer) limit(memoryMB int64, cpus float64, pids int64) error {
+	out, err := exec.Command("docker", "update",
+		fmt.Sprintf("--memory=%dm", memoryMB),
+		fmt.Sprintf("--memory-swap=%dm", memoryMB),
+		fmt.Sprintf("--cpus=%.2f", cpus),
+		fmt.Sprintf("--pids-limit=%d", pids),
+		c.id).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to apply sandbox limits: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// resetPeak starts a new memory high-water mark for the container's next run, from the host since
+// the container only sees its cgroup read-only. On cgroup v2 (kernel 6.12 or later) the mark is
+// reset for the returned file alone and the run's peak is read back through it, on cgroup v1
+// the container's own mark is reset and runScript reads it as usual, so the file is nil.
+func (c *warmContainer) resetPeak() (*os.File, error) {
+	dir, err := c.cgroupDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "memory.max_usage_in_bytes")); err == nil {
+		if err := os.WriteFile(filepath.Join(dir, "memory.max_usage_in_bytes"), []byte("0"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to reset sandbox memory peak: %v", err)
+		}
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "memory.peak"), os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sandbox memory peak: %v", err)
+	}
+	if _, err := f.WriteString("reset"); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to reset sandbox memory peak: %v", err)
+	}
+	return f, nil
+}
+
+// cgroupDir finds the host path of the container's memory cgroup through its init process
+func (c *warmContainer) cgroupDir() (string, error) {
+	if c.memoryCgroup != "" {
+		return c.memoryCgroup, nil
+	}
+
+	out, err := exec.Command("docker", "inspect", "-f", "{{.State.Pid}}", c.id).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find sandbox process: %v", err)
+	}
+	cgroups, err := os.ReadFile(filepath.Join("/proc", strings.TrimSpace(string(out)), "cgroup"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read sandbox cgroup: %v", err)
+	}
+
+	// Lines are id:controllers:path, a v1 memory hierarchy wins over the v2 one on hybrid hosts
+	var dir string
+	for _, line := range strings.Split(strings.TrimSpace(string(cgroups)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" && dir == "" {
+			dir = filepath.Join("/sys/fs/cgroup", parts[2])
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller == "memory" {
+				dir = filepath.Join("/sys/fs/cgroup/memory", parts[2])
+			}
+		}
+	}
+	if dir == "" {
+		return "", fmt.Errorf("sandbox %s has no memory cgroup", c.id)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("failed to find sandbox cgroup: %v", err)
+	}
+	c.memoryCgroup = dir
+	return dir, nil
+}
+
+// readPeak reads a run's peak memory in bytes back through the file resetPeak returned
+func readPeak(f *os.File) (int64, error) {
+	if f == nil {
+		return 0, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// recordPeak overrides the cumulative peak runScript saw with the run's own, readUsage keeps
+// the last value of a key. Scripts that leave no meta file have no use for it.
+func recordPeak(dir string, peakBytes int64) error {
+	path := filepath.Join(dir, resultDir, "meta.txt")
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to record memory peak: %v", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "memory=%d\n", peakBytes)
+	return err
+}
+
+func isRunning(id string) bool {
+	out, err := exec.Command("docker", "inspect", "-f", "{{.State.Running}}", id).Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+func removeContainer(id string) {
+	exec.Command("docker", "rm", "-f", id).Run()
+}
+
+// remove stops the container and drops its input directory
+func (c *warmContainer) remove() {
+	removeContainer(c.id)
+	os.RemoveAll(c.inputDir)
+}
@@ -0,0 +1,435 @@
+package cppruner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+)
+
+// SubmissionStatus is where a queued submission is in its life cycle
+type SubmissionStatus string
+
+const (
+	StatusQueued    SubmissionStatus = "queued"
+	StatusCompiling SubmissionStatus = "compiling"
+	StatusRunning   SubmissionStatus = "running"
+	StatusDone      SubmissionStatus = "done"
+	StatusFailed    SubmissionStatus = "failed"
+)
+
+// how long a finished submission can still be polled
+const submissionStatusTTL = time.Hour
+
+// A server sharing the Redis queue renews its heartbeat every queueHeartbeatInterval, the jobs
+// it holds go back to the queue once it missed the beats of queueHeartbeatTTL
+const (
+	queueHeartbeatInterval = 10 * time.Second
+	queueHeartbeatTTL      = 3 * queueHeartbeatInterval
+)
+
+// JudgeJob is one submission waiting in the queue
+type JudgeJob struct {
+	ID        string     `json:"id"`
+	ProblemID uint       `json:"problem_id"`
+	UserID    uint       `json:"user_id"`
+	Language  string     `json:"language"`
+	Code      string     `json:"code"`
+	TestCases []TestCase `json:"test_cases"`
+	// StopPolicy defaults to RunAll
+	StopPolicy StopPolicy `json:"stop_policy,omitempty"`
+
+	// Run makes the job a custom run on Inputs (the problem's examples without any),
+	// only judge backends take these, the queue is for submissions
+	Run    bool     `json:"run,omitempty"`
+	Inputs []string `json:"inputs,omitempty"`
+
+	// Hack makes the job a hack of Code (the defender's, UserID) with HackInput, the outcome
+	// comes back in JudgeResult.Hack. Like runs, only judge backends take these.
+	Hack      bool   `json:"hack,omitempty"`
+	HackInput string `json:"hack_input,omitempty"`
+
+	// Stress makes the job a stress test of Code against the reference on StressRuns generated
+	// inputs from StressSeed, Reference regenerates the expected outputs of ProblemID. Their
+	// reports come back in JudgeResult.Stress and JudgeResult.Reference, only judge backends take these.
+	Stress     bool  `json:"stress,omitempty"`
+	StressRuns int   `json:"stress_runs,omitempty"`
+	StressSeed int64 `json:"stress_seed,omitempty"`
+	Reference  bool  `json:"reference,omitempty"`
+}
+
+// SubmissionState is what clients poll or get pushed for a submission
+type SubmissionState struct {
+	ID          string           `json:"id"`
+	UserID      uint             `json:"user_id"`
+	ProblemID   uint             `json:"problem_id"`
+	Language    string           `json:"language"`
+	Status      SubmissionStatus `json:"status"`
+	CurrentTest int              `json:"current_test,omitempty"`
+	TotalTests  int              `json:"total_tests"`
+	Result      *JudgeResult     `json:"result,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// Finished reports whether the submission reached a terminal status
+func (s SubmissionState) Finished() bool {
+	return s.Status == StatusDone || s.Status == StatusFailed
+}
+
+// progressFunc is called by the judge as a submission moves through compiling and running
+type progressFunc func(status SubmissionStatus, test int)
+
+func (p progressFunc) report(status SubmissionStatus, test int) {
+	if p != nil {
+		p(status, test)
+	}
+}
+
+// queueBackend stores jobs and statuses, Redis when available and memory otherwise
+type queueBackend interface {
+	push(job []byte) error
+	pop(timeout time.Duration) ([]byte, error)
+	ack(job []byte) error
+	saveState(id string, state []byte) error
+	loadState(id string) ([]byte, error)
+}
+
+// JudgeQueue decouples submitting code from judging it: Submit returns an ID right
+// away and a pool of workers judges jobs in the background
+type JudgeQueue struct {
+	db       *database.Databse
+	judge    JudgeBackend
+	backend  queueBackend
+	workers  int
+	mu       sync.Mutex
+	watchers map[string][]func(SubmissionState)
+}
+
+// QueueWorkersFromEnv reads JUDGE_WORKERS, the number of submissions judged at once
+func QueueWorkersFromEnv() int {
+	if n, err := strconv.Atoi(os.Getenv("JUDGE_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+func NewJudgeQueue(db *database.Databse, workers int, judge JudgeBackend) *JudgeQueue {
+	q := &JudgeQueue{
+		db:       db,
+		judge:    judge,
+		workers:  workers,
+		watchers: make(map[string][]func(SubmissionState)),
+	}
+
+	if db.Cache != nil {
+		server, err := newSubmissionID()
+		if err != nil {
+			// Not random but still this server's own, only a clash with another server would hurt
+			server = fmt.Sprintf("%d", time.Now().UnixNano())
+		}
+		backend := &redisBackend{cache: db.Cache, server: server}
+		q.backend = backend
+		// Beating before the first pop keeps other servers off this server's jobs
+		backend.beat()
+		go backend.keepAlive()
+		go q.listen(db.Cache.SubscribeSubmissionStatus())
+	} else {
+		fmt.Println("Warning: Redis is not available, judge queue is kept in memory")
+		q.backend = newMemoryBackend()
+	}
+
+	return q
+}
+
+// Start launches the worker pool
+func (q *JudgeQueue) Start() {
+	for i := 0; i < q.workers; i++ {
+		go q.work(i + 1)
+	}
+	fmt.Printf("Judge queue started with %d workers\n", q.workers)
+}
+
+// Submit enqueues a job and returns its submission ID immediately, onUpdate (may be nil)
+// is registered before the job is queued so no transition can be missed
+func (q *JudgeQueue) Submit(job JudgeJob, onUpdate func(SubmissionState)) (string, error) {
+	if _, err := GetLanguage(job.Language); err != nil {
+		return "", err
+	}
+
+	id, err := newSubmissionID()
+	if err != nil {
+		return "", err
+	}
+	job.ID = id
+
+	if onUpdate != nil {
+		q.Watch(id, onUpdate)
+	}
+
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode judge job: %v", err)
+	}
+
+	q.publish(SubmissionState{
+		ID:         id,
+		UserID:     job.UserID,
+		ProblemID:  job.ProblemID,
+		Language:   job.Language,
+		Status:     StatusQueued,
+		TotalTests: len(job.TestCases),
+	})
+
+	if err := q.backend.push(payload); err != nil {
+		q.mu.Lock()
+		delete(q.watchers, id)
+		q.mu.Unlock()
+		return "", fmt.Errorf("failed to enqueue submission: %v", err)
+	}
+
+	return id, nil
+}
+
+// Status returns the latest known state of a submission
+func (q *JudgeQueue) Status(id string) (*SubmissionState, error) {
+	data, err := q.backend.loadState(id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("submission %s not found", id)
+	}
+
+	var state SubmissionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode submission status: %v", err)
+	}
+	return &state, nil
+}
+
+// Watch calls fn on every status transition of a submission until it finishes
+func (q *JudgeQueue) Watch(id string, fn func(SubmissionState)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.watchers[id] = append(q.watchers[id], fn)
+}
+
+func (q *JudgeQueue) work(worker int) {
+	for {
+		payload, err := q.backend.pop(5 * time.Second)
+		if err != nil {
+			fmt.Printf("Judge worker %d failed to read the queue: %v\n", worker, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if payload == nil {
+			continue
+		}
+
+		var job JudgeJob
+		if err := json.Unmarshal(payload, &job); err != nil {
+			fmt.Printf("Judge worker %d dropped a malformed job: %v\n", worker, err)
+			q.backend.ack(payload)
+			continue
+		}
+
+		fmt.Printf("Judge worker %d picked up submission %s\n", worker, job.ID)
+		q.process(job)
+
+		if err := q.backend.ack(payload); err != nil {
+			fmt.Printf("Failed to ack submission %s: %v\n", job.ID, err)
+		}
+	}
+}
+
+func (q *JudgeQueue) process(job JudgeJob) {
+	state := SubmissionState{
+		ID:         job.ID,
+		UserID:     job.UserID,
+		ProblemID:  job.ProblemID,
+		Language:   job.Language,
+		TotalTests: len(job.TestCases),
+	}
+
+	progress := func(status SubmissionStatus, test int) {
+		state.Status = status
+		state.CurrentTest = test
+		q.publish(state)
+	}
+
+	result, err := q.judge.Judge(job, progress)
+	state.CurrentTest = 0
+	if err != nil {
+		state.Error = err.Error()
+		if result.Verdict == VerdictCompileError {
+			state.Status = StatusDone
+			state.Result = &result
+		} else {
+			state.Status = StatusFailed
+		}
+	} else {
+		state.Status = StatusDone
+		state.Result = &result
+	}
+
+	q.publish(state)
+}
+
+// publish stores a state and notifies watchers, through Redis pub/sub when the
+// queue is shared so the server holding the player's socket hears about it
+func (q *JudgeQueue) publish(state SubmissionState) {
+	state.UpdatedAt = time.Now()
+	payload, err := json.Marshal(state)
+	if err != nil {
+		fmt.Printf("Failed to encode submission status: %v\n", err)
+		return
+	}
+
+	if err := q.backend.saveState(state.ID, payload); err != nil {
+		fmt.Printf("Failed to save submission status: %v\n", err)
+	}
+
+	if q.db.Cache != nil {
+		if err := q.db.Cache.PublishSubmissionStatus(payload); err != nil {
+			fmt.Printf("Failed to publish submission status: %v\n", err)
+		}
+		return
+	}
+	q.notify(state)
+}
+
+func (q *JudgeQueue) listen(updates <-chan []byte) {
+	for payload := range updates {
+		var state SubmissionState
+		if err := json.Unmarshal(payload, &state); err != nil {
+			continue
+		}
+		q.notify(state)
+	}
+}
+
+func (q *JudgeQueue) notify(state SubmissionState) {
+	q.mu.Lock()
+	fns := q.watchers[state.ID]
+	if state.Finished() {
+		delete(q.watchers, state.ID)
+	}
+	q.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(state)
+	}
+}
+
+func newSubmissionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate submission id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// redisBackend shares the queue between servers, server names this server's processing list
+type redisBackend struct {
+	cache  *database.Redis
+	server string
+}
+
+func (b *redisBackend) push(job []byte) error { return b.cache.EnqueueJudgeJob(job) }
+func (b *redisBackend) pop(timeout time.Duration) ([]byte, error) {
+	return b.cache.DequeueJudgeJob(b.server, timeout)
+}
+func (b *redisBackend) ack(job []byte) error { return b.cache.AckJudgeJob(b.server, job) }
+
+// beat renews this server's heartbeat and requeues the jobs of servers that stopped beating
+func (b *redisBackend) beat() {
+	if err := b.cache.JudgeHeartbeat(b.server, queueHeartbeatTTL); err != nil {
+		fmt.Printf("Failed to renew the judge queue heartbeat: %v\n", err)
+	}
+	if moved, err := b.cache.RequeueOrphanedJobs(); err != nil {
+		fmt.Printf("Failed to requeue unfinished judge jobs: %v\n", err)
+	} else if moved > 0 {
+		fmt.Printf("Requeued %d unfinished judge jobs of stopped servers\n", moved)
+	}
+}
+
+func (b *redisBackend) keepAlive() {
+	for range time.Tick(queueHeartbeatInterval) {
+		b.beat()
+	}
+}
+func (b *redisBackend) saveState(id string, state []byte) error {
+	return b.cache.SaveSubmissionStatus(id, state, submissionStatusTTL)
+}
+func (b *redisBackend) loadState(id string) ([]byte, error) { return b.cache.GetSubmissionStatus(id) }
+
+type storedState struct {
+	data    []byte
+	savedAt time.Time
+}
+
+// memoryBackend is the single-server fallback, jobs are lost on restart
+type memoryBackend struct {
+	jobs   chan []byte
+	mu     sync.Mutex
+	states map[string]storedState
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		jobs:   make(chan []byte, 1000),
+		states: make(map[string]storedState),
+	}
+}
+
+func (b *memoryBackend) push(job []byte) error {
+	select {
+	case b.jobs <- job:
+		return nil
+	default:
+		return fmt.Errorf("judge queue is full")
+	}
+}
+
+func (b *memoryBackend) pop(timeout time.Duration) ([]byte, error) {
+	select {
+	case job := <-b.jobs:
+		return job, nil
+	case <-time.After(timeout):
+		return nil, nil
+	}
+}
+
+func (b *memoryBackend) ack(job []byte) error { return nil }
+
+func (b *memoryBackend) saveState(id string, state []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Forget expired submissions while we hold the lock anyway
+	for key, s := range b.states {
+		if time.Since(s.savedAt) > submissionStatusTTL {
+			delete(b.states, key)
+		}
+	}
+	b.states[id] = storedState{data: state, savedAt: time.Now()}
+	return nil
+}
+
+func (b *memoryBackend) loadState(id string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.states[id]
+	if !ok {
+		return nil, nil
+	}
+	return s.data, nil
+}
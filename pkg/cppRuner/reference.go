@@ -0,0 +1,190 @@
+package cppruner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// ReferenceReport is what generating a problem's expected outputs from its reference solution found
+type ReferenceReport struct {
+	ProblemID uint            `json:"problem_id"`
+	Checksum  string          `json:"checksum"`
+	Tests     []ReferenceTest `json:"tests"`
+	// Flagged counts the tests the reference didn't pass within the limits
+	Flagged int `json:"flagged"`
+}
+
+// ReferenceTest is the reference solution's run on one test case. A flagged test keeps its
+// previous expected output, the author has to fix the reference, the limits or the input.
+type ReferenceTest struct {
+	TestCaseID uint    `json:"test_case_id"`
+	Verdict    Verdict `json:"verdict"`
+	TimeMs     int64   `json:"time_ms"`
+	MemoryKB   int64   `json:"memory_kb"`
+	Flagged    bool    `json:"flagged"`
+	// Changed is set when the stored expected output was different before
+	Changed bool `json:"changed"`
+}
+
+// ReferenceChecksum identifies everything the expected outputs of problem depend on: the reference
+// solution, the limits it runs with (server defaults included) and the test inputs
+func ReferenceChecksum(problem *modles.ProblemPropaty) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%+v\x00", referenceLanguage(problem), problem.ReferenceSource, ProblemLimits(problem))
+	for _, tc := range problem.TestCases {
+		fmt.Fprintf(h, "%d\x00%s\x00", tc.ID, tc.Input)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GenerateExpectedOutputs runs the problem's reference solution on every test case and stores its
+// answers as the expected outputs, tests where it breaks a limit are flagged instead
+func GenerateExpectedOutputs(sandbox SandboxBackend, problemId uint, db *database.Databse) (ReferenceReport, error) {
+	report := ReferenceReport{ProblemID: problemId}
+
+	// Straight from the database, the cache may hold the problem from before an edit
+	var problem modles.ProblemPropaty
+	if err := db.Db.Preload("TestCases").Where("id = ?", problemId).First(&problem).Error; err != nil {
+		return report, fmt.Errorf("problem with id %d not found: %v", problemId, err)
+	}
+	switch {
+	case problem.Interactive:
+		return report, fmt.Errorf("problem %d is interactive, its interactor decides the verdict", problemId)
+	case problem.ReferenceSource == "":
+		return report, fmt.Errorf("problem %d has no reference solution", problemId)
+	}
+	report.Checksum = ReferenceChecksum(&problem)
+
+	// The reference is assembled with the problem's templates and limits, which may just have changed
+	if db.Cache != nil {
+		db.Cache.ClearproblemCache(problemId)
+	}
+
+	inputs := make([]string, len(problem.TestCases))
+	for i, tc := range problem.TestCases {
+		inputs[i] = tc.Input
+	}
+
+	var cases []TestResult
+	if len(inputs) > 0 {
+		var err error
+		if cases, err = runReference(sandbox, &problem, inputs, db); err != nil {
+			return report, err
+		}
+	}
+
+	for i, tc := range problem.TestCases {
+		c := cases[i]
+		test := ReferenceTest{TestCaseID: tc.ID, Verdict: c.Verdict, TimeMs: c.TimeMs, MemoryKB: c.MemoryKB}
+
+		update := map[string]interface{}{
+			"reference_verdict": string(c.Verdict),
+			"reference_time_ms": c.TimeMs,
+		}
+		// Stdout is cut at maxStdoutBytes, a cut answer can't be stored
+		if c.Verdict == VerdictOK && len(c.Stdout) > maxStdoutBytes {
+			test.Verdict = VerdictOutputLimit
+			update["reference_verdict"] = string(VerdictOutputLimit)
+		}
+
+		if test.Verdict == VerdictOK {
+			test.Changed = tc.ExpectedOutput != c.Stdout
+			update["expected_output"] = c.Stdout
+		} else {
+			test.Flagged = true
+			report.Flagged++
+			fmt.Printf("Reference solution of problem %d got %s on test case %d\n", problemId, test.Verdict, tc.ID)
+		}
+
+		if err := db.Db.Model(&modles.TestCaesPropaty{}).Where("id = ?", tc.ID).Updates(update).Error; err != nil {
+			return report, fmt.Errorf("failed to update test case %d: %v", tc.ID, err)
+		}
+		report.Tests = append(report.Tests, test)
+	}
+
+	if err := db.Db.Model(&modles.ProblemPropaty{}).Where("id = ?", problemId).Update("reference_checksum", report.Checksum).Error; err != nil {
+		return report, fmt.Errorf("failed to update problem %d: %v", problemId, err)
+	}
+
+	// The judge reads test cases through the cache
+	if db.Cache != nil {
+		db.Cache.ClearproblemCache(problemId)
+	}
+	return report, nil
+}
+
+// GenerateExpectedOutputsOn regenerates expected outputs through judge, on the judge nodes when there
+// are any, like GenerateExpectedOutputs does in-process. Nodes write to the shared database, the
+// problem's cache entry is cleared here too in case the node cleared a different cache.
+func GenerateExpectedOutputsOn(judge JudgeBackend, problemId uint, db *database.Databse) (ReferenceReport, error) {
+	result, err := judge.Judge(JudgeJob{ProblemID: problemId, Reference: true}, nil)
+	if err != nil {
+		return ReferenceReport{ProblemID: problemId}, err
+	}
+	if result.Reference == nil {
+		return ReferenceReport{}, fmt.Errorf("judge returned no reference report")
+	}
+	if db.Cache != nil {
+		db.Cache.ClearproblemCache(problemId)
+	}
+	return *result.Reference, nil
+}
+
+// RevalidateProblems regenerates the expected outputs of every problem whose reference solution,
+// limits or test inputs changed since the last run through judge, a problem that fails is logged and skipped
+func RevalidateProblems(judge JudgeBackend, db *database.Databse) error {
+	var problems []modles.ProblemPropaty
+	if err := db.Db.Preload("TestCases").Where("reference_source <> '' AND interactive = ?", false).Find(&problems).Error; err != nil {
+		return fmt.Errorf("failed to fetch problems: %v", err)
+	}
+
+	for i := range problems {
+		problem := &problems[i]
+		if problem.ReferenceChecksum == ReferenceChecksum(problem) {
+			continue
+		}
+
+		fmt.Printf("Reference or limits of problem %d changed, regenerating expected outputs\n", problem.ID)
+		report, err := GenerateExpectedOutputsOn(judge, problem.ID, db)
+		if err != nil {
+			fmt.Printf("Failed to regenerate expected outputs of problem %d: %v\n", problem.ID, err)
+			continue
+		}
+		fmt.Printf("Problem %d: %d test cases, %d flagged\n", problem.ID, len(report.Tests), report.Flagged)
+	}
+	return nil
+}
+
+// runReference runs the problem's reference solution on inputs with the problem's limits and
+// returns every run, the caller decides what a failing one means
+func runReference(sandbox SandboxBackend, problem *modles.ProblemPropaty, inputs []string, db *database.Databse) ([]TestResult, error) {
+	task, _, err := newJudgeTask(sandbox, 0, problem.ID, referenceLanguage(problem), problem.ReferenceSource, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare the reference solution: %v", err)
+	}
+	defer os.RemoveAll(task.dir)
+
+	task.keepOutput = true
+	task.checker = checkerSpec{name: checkerNone}
+	for _, input := range inputs {
+		task.testCases = append(task.testCases, TestCase{Input: input})
+	}
+
+	result, err := task.judge()
+	if err != nil {
+		return nil, fmt.Errorf("reference solution failed: %v", err)
+	}
+	return result.Cases, nil
+}
+
+func referenceLanguage(problem *modles.ProblemPropaty) string {
+	if problem.ReferenceLanguage == "" {
+		return DefaultLanguage
+	}
+	return problem.ReferenceLanguage
+}
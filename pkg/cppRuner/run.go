@@ -0,0 +1,59 @@
+package cppruner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+)
+
+// Bounds on what a single custom run may ask for
+const (
+	MaxRunInputs     = 5
+	MaxRunInputBytes = 64 * 1024
+)
+
+// RunCode compiles code with the problem's header and main and executes it on inputs,
+// or on the problem's examples when inputs is empty. Nothing is recorded: custom runs
+// don't touch submissions, stats or the match.
+func RunCode(sandbox SandboxBackend, userID uint, problemId uint, language string, code string, inputs []string, db *database.Databse) (JudgeResult, error) {
+	if len(inputs) > MaxRunInputs {
+		return JudgeResult{}, fmt.Errorf("at most %d inputs can be run at once", MaxRunInputs)
+	}
+	for _, input := range inputs {
+		if len(input) > MaxRunInputBytes {
+			return JudgeResult{}, fmt.Errorf("input is too long (maximum %d bytes)", MaxRunInputBytes)
+		}
+	}
+
+	task, problem, err := newJudgeTask(sandbox, userID, problemId, language, code, db)
+	if err != nil {
+		return JudgeResult{}, err
+	}
+	defer os.RemoveAll(task.dir)
+
+	task.keepOutput = true
+
+	if len(inputs) > 0 {
+		// Custom stdin has no answer, so the output is only shown, not checked
+		if !task.interactive {
+			task.checker = checkerSpec{name: checkerNone}
+		}
+		for _, input := range inputs {
+			task.testCases = append(task.testCases, TestCase{Input: input})
+		}
+	} else {
+		for _, example := range problem.Examples {
+			task.testCases = append(task.testCases, TestCase{
+				Input:          example.Input,
+				ExpectedOutput: example.ExpectedOutput,
+			})
+		}
+	}
+
+	if len(task.testCases) == 0 {
+		return JudgeResult{}, fmt.Errorf("problem %d has no examples, provide an input to run", problemId)
+	}
+
+	return task.judge()
+}
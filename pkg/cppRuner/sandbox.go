@@ -0,0 +1,272 @@
+package cppruner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SandboxBackend hands out a Sandbox per submission and toolchain image
+type SandboxBackend interface {
+	// NewSandbox prepares a sandbox for the programs in the host directory dir
+	NewSandbox(image, dir string) (Sandbox, error)
+}
+
+// Sandbox builds and runs the programs of one submission in isolation
+type Sandbox interface {
+	// Compile runs cmd in the work directory and leaves the artifacts in dir,
+	// a failing build comes back as *CompileError
+	Compile(cmd []string) ([]byte, error)
+	// WriteInput makes input the test data for the following runs, the program sees it
+	// read-only at inputFile and can't change it for later test cases
+	WriteInput(input string) error
+	// Run executes the shell script in the work directory with stdin under limits,
+	// whatever the script leaves in resultDir is available in dir afterwards
+	Run(ctx context.Context, script string, stdin string, limits Limits) ([]byte, error)
+	// Pipe runs script like Run with its stdin and stdout streamed while it runs, stdout is closed
+	// as soon as the script's output ends. Only stderr is returned.
+	Pipe(ctx context.Context, script string, stdin io.Reader, stdout io.WriteCloser, limits Limits) ([]byte, error)
+	// Cleanup releases everything the sandbox holds
+	Cleanup()
+}
+
+// Sandbox backends selectable with JUDGE_SANDBOX
+const (
+	SandboxDocker = "docker"
+	SandboxLocal  = "local"
+)
+
+var (
+	defaultSandbox     SandboxBackend
+	defaultSandboxErr  error
+	defaultSandboxOnce sync.Once
+)
+
+// DefaultSandbox returns the backend picked by JUDGE_SANDBOX: docker (default), through
+// the warm pool unless JUDGE_POOL_SIZE=0, or local for machines without docker
+func DefaultSandbox() (SandboxBackend, error) {
+	defaultSandboxOnce.Do(func() {
+		switch name := os.Getenv("JUDGE_SANDBOX"); name {
+		case "", SandboxDocker:
+			defaultSandbox = NewDockerSandbox()
+		case SandboxLocal:
+			defaultSandbox, defaultSandboxErr = NewLocalSandbox(LocalConfigFromEnv())
+		default:
+			defaultSandboxErr = fmt.Errorf("unknown sandbox backend: %s", name)
+		}
+	})
+	return defaultSandbox, defaultSandboxErr
+}
+
+// NewDockerSandbox returns the warm sandbox pool, or one docker run per step when the pool is disabled
+func NewDockerSandbox() SandboxBackend {
+	if pool := DefaultPool(); pool != nil {
+		return pool
+	}
+	return dockerRunBackend{}
+}
+
+// dockerRunBackend starts a fresh container for the compile step and for every run
+type dockerRunBackend struct{}
+
+type dockerRunSandbox struct {
+	image string
+	dir   string
+	// inputDir holds input.txt on the host, mounted read-only at /input
+	inputDir string
+}
+
+func (dockerRunBackend) NewSandbox(image, dir string) (Sandbox, error) {
+	// Check if Docker is available
+	if err := checkDocker(); err != nil {
+		return nil, err
+	}
+	if err := ensureImage(image); err != nil {
+		return nil, err
+	}
+
+	// Containers run as nobody, which has to be able to write the build artifacts and results
+	if err := os.Chmod(dir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to open up work directory: %v", err)
+	}
+	inputDir, err := newInputDir()
+	if err != nil {
+		return nil, err
+	}
+	return &dockerRunSandbox{image: image, dir: dir, inputDir: inputDir}, nil
+}
+
+// Compile runs the build over dir in a throwaway container
+func (s *dockerRunSandbox) Compile(compileCmd []string) ([]byte, error) {
+	return dockerRunCompile(s.image, s.dir, compileCmd)
+}
+
+func (s *dockerRunSandbox) WriteInput(input string) error {
+	return writeInputFile(s.inputDir, input)
+}
+
+// Run mounts dir read-only into a fresh container with only resultDir writable,
+// so results land in dir directly
+func (s *dockerRunSandbox) Run(ctx context.Context, script string, stdin string, limits Limits) ([]byte, error) {
+	cmd, err := s.command(ctx, script, limits)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+	return out.Bytes(), err
+}
+
+func (s *dockerRunSandbox) Pipe(ctx context.Context, script string, stdin io.Reader, stdout io.WriteCloser, limits Limits) ([]byte, error) {
+	cmd, err := s.command(ctx, script, limits)
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+	return runPiped(cmd, stdin, stdout)
+}
+
+// command is the docker run of one script, the container is gone once it exits
+// and takes every process the script started with it
+func (s *dockerRunSandbox) command(ctx context.Context, script string, limits Limits) (*exec.Cmd, error) {
+	outDir := filepath.Join(s.dir, resultDir)
+	if err := os.MkdirAll(outDir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to create result directory: %v", err)
+	}
+	if err := os.Chmod(outDir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to open up result directory: %v", err)
+	}
+
+	security, err := securityArgs(sandboxUser, DefaultJudgeSettings().PidsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"run", "--rm", "-i",
+		"-v", fmt.Sprintf("%s:/code:ro", s.dir),
+		"-v", fmt.Sprintf("%s:/code/%s", outDir, resultDir),
+		"-v", fmt.Sprintf("%s:/input:ro", s.inputDir),
+		"-w", "/code",
+		fmt.Sprintf("--memory=%dm", limits.MemoryMB), // Memory limit for execution
+		fmt.Sprintf("--memory-swap=%dm", limits.MemoryMB),
+		fmt.Sprintf("--cpus=%.2f", DefaultJudgeSettings().CPUs), // CPU limit for execution
+		"--network=none", // No network access
+	}
+	args = append(args, security...)
+	return exec.CommandContext(ctx, "docker", append(args, s.image, "sh", "-c", script)...), nil
+}
+
+func (s *dockerRunSandbox) Cleanup() {
+	os.RemoveAll(s.inputDir)
+}
+
+// dockerRunCompile runs a compile command over dir in a throwaway container,
+// a failing build comes back as *CompileError
+func dockerRunCompile(image, dir string, compileCmd []string) ([]byte, error) {
+	timeout := DefaultJudgeSettings().CompileTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	security, err := securityArgs(sandboxUser, compilePidsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"run", "--rm",
+		"-v", fmt.Sprintf("%s:/code", dir),
+		"-w", "/code",
+		"--memory=512m",  // Increased memory limit
+		"--cpus=1.0",     // CPU limit
+		"--network=none", // No network access
+	}
+	args = append(args, security...)
+	args = append(args, image)
+	cmd := exec.CommandContext(ctx, "docker", append(args, compileCmd...)...)
+
+	fmt.Printf("Running compilation command: %v\n", cmd.Args)
+
+	compileOutput, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return compileOutput, fmt.Errorf("compilation timed out after %v", timeout)
+		}
+		fmt.Printf("Docker command failed: %v\n", err)
+		fmt.Printf("Compile output: %s\n", string(compileOutput))
+		return compileOutput, &CompileError{Output: string(compileOutput)}
+	}
+	return compileOutput, nil
+}
+
+// runPiped runs cmd with stdin and stdout streamed and returns its stderr. stdout is closed
+// once the command's output ends, so a reader sees EOF even while the command waits for input;
+// output nobody takes anymore is thrown away.
+func runPiped(cmd *exec.Cmd, stdin io.Reader, stdout io.WriteCloser) ([]byte, error) {
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		stdout.Close()
+		return nil, err
+	}
+
+	if _, err := io.Copy(stdout, pipe); err != nil {
+		io.Copy(io.Discard, pipe)
+	}
+	stdout.Close()
+	err = cmd.Wait()
+	return stderr.Bytes(), err
+}
+
+func checkDocker() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := exec.CommandContext(ctx, "docker", "version").Run(); err != nil {
+		return fmt.Errorf("docker is not available or not running: %v", err)
+	}
+	return nil
+}
+
+// ensureImage pulls the toolchain image if it is not present locally
+func ensureImage(image string) error {
+	fmt.Printf("Checking for %s image...\n", image)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "docker", "images", "-q", image).Output()
+	if err == nil && len(strings.TrimSpace(string(output))) > 0 {
+		fmt.Printf("%s image found locally\n", image)
+		return nil
+	}
+
+	fmt.Printf("%s image not found locally, pulling...\n", image)
+	// Pull with timeout
+	pullCtx, pullCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer pullCancel()
+
+	pullOutput, pullErr := exec.CommandContext(pullCtx, "docker", "pull", image).CombinedOutput()
+	if pullErr != nil {
+		fmt.Printf("Failed to pull %s image: %v\n", image, pullErr)
+		fmt.Printf("Pull output: %s\n", string(pullOutput))
+		return fmt.Errorf("failed to pull %s image: %v", image, pullErr)
+	}
+	fmt.Printf("Successfully pulled %s image\n", image)
+	return nil
+}
@@ -0,0 +1,123 @@
+package cppruner
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// MaxScore is what a submission passing every test case scores
+const MaxScore = 100.0
+
+// Subtask scoring policies
+const (
+	// PolicyMin awards a subtask's points only when every test in it passes
+	PolicyMin = "min"
+	// PolicySum splits a subtask's points between its tests by weight
+	PolicySum = "sum"
+)
+
+// SubtaskResult is how one group of test cases scored
+type SubtaskResult struct {
+	Group  int     `json:"group"`
+	Points float64 `json:"points"`
+	Earned float64 `json:"earned"`
+	Passed int     `json:"passed"`
+	Total  int     `json:"total"`
+	// Skipped is set when a group it depends on was not fully solved
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// score fills in the result's score out of MaxScore. Without subtasks every test is
+// worth its weight; with subtasks each group scores by its policy and dependencies.
+func score(result *JudgeResult, testCases []TestCase, subtasks []modles.Subtask) {
+	if len(result.Cases) == 0 || len(result.Cases) != len(testCases) {
+		return
+	}
+
+	passed := make([]bool, len(testCases))
+	for i, c := range result.Cases {
+		passed[i] = c.Verdict == VerdictAccepted
+	}
+
+	if len(subtasks) == 0 {
+		var earned, total float64
+		for i, tc := range testCases {
+			total += tc.weight()
+			if passed[i] {
+				earned += tc.weight()
+			}
+		}
+		result.Score = roundScore(MaxScore * earned / total)
+		return
+	}
+
+	sorted := append([]modles.Subtask(nil), subtasks...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Group < sorted[j].Group })
+
+	solved := map[int]bool{}
+	var total float64
+	for _, st := range sorted {
+		sr := SubtaskResult{Group: st.Group, Points: st.Points}
+
+		var earned, weight float64
+		for i, tc := range testCases {
+			if tc.Group != st.Group {
+				continue
+			}
+			sr.Total++
+			weight += tc.weight()
+			if passed[i] {
+				sr.Passed++
+				earned += tc.weight()
+			}
+		}
+		solved[st.Group] = sr.Total > 0 && sr.Passed == sr.Total
+
+		for _, dep := range dependencies(st.DependsOn) {
+			if !solved[dep] {
+				sr.Skipped = true
+			}
+		}
+
+		switch {
+		case sr.Skipped || sr.Total == 0:
+		case strings.EqualFold(st.Policy, PolicySum):
+			sr.Earned = roundScore(st.Points * earned / weight)
+		case solved[st.Group]:
+			sr.Earned = st.Points
+		}
+
+		// A skipped group counts as unsolved for anything depending on it
+		solved[st.Group] = solved[st.Group] && !sr.Skipped
+		total += sr.Earned
+		result.Subtasks = append(result.Subtasks, sr)
+	}
+
+	result.Score = roundScore(math.Min(total, MaxScore))
+}
+
+func (tc TestCase) weight() float64 {
+	if tc.Weight <= 0 {
+		return 1
+	}
+	return tc.Weight
+}
+
+// dependencies parses Subtask.DependsOn, entries that aren't numbers are ignored
+func dependencies(dependsOn string) []int {
+	var groups []int
+	for _, field := range strings.Split(dependsOn, ",") {
+		if group, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func roundScore(s float64) float64 {
+	return math.Round(s*100) / 100
+}
@@ -0,0 +1,89 @@
+package cppruner
+
+import (
+	"testing"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+func TestScore(t *testing.T) {
+	ac, wa := VerdictAccepted, VerdictWrongAnswer
+
+	tests := []struct {
+		name      string
+		verdicts  []Verdict
+		testCases []TestCase
+		subtasks  []modles.Subtask
+		want      float64
+		// wantEarned is what each subtask earned, in group order
+		wantEarned []float64
+	}{
+		{
+			name:      "all passed",
+			verdicts:  []Verdict{ac, ac},
+			testCases: []TestCase{{}, {}},
+			want:      100,
+		},
+		{
+			name:      "weighted without subtasks",
+			verdicts:  []Verdict{ac, wa, ac},
+			testCases: []TestCase{{Weight: 2}, {Weight: 1}, {}},
+			want:      75,
+		},
+		{
+			name:       "min policy needs every test",
+			verdicts:   []Verdict{ac, wa, ac},
+			testCases:  []TestCase{{Group: 1}, {Group: 1}, {Group: 2}},
+			subtasks:   []modles.Subtask{{Group: 1, Points: 40, Policy: PolicyMin}, {Group: 2, Points: 60, Policy: PolicyMin}},
+			want:       60,
+			wantEarned: []float64{0, 60},
+		},
+		{
+			name:       "sum policy splits by weight",
+			verdicts:   []Verdict{ac, wa, wa},
+			testCases:  []TestCase{{Group: 1, Weight: 2}, {Group: 1}, {Group: 1, Weight: 1}},
+			subtasks:   []modles.Subtask{{Group: 1, Points: 100, Policy: PolicySum}},
+			want:       50,
+			wantEarned: []float64{50},
+		},
+		{
+			name:       "dependency not solved",
+			verdicts:   []Verdict{wa, ac},
+			testCases:  []TestCase{{Group: 1}, {Group: 2}},
+			subtasks:   []modles.Subtask{{Group: 2, Points: 70, DependsOn: "1"}, {Group: 1, Points: 30}},
+			want:       0,
+			wantEarned: []float64{0, 0},
+		},
+		{
+			name:       "skipped group breaks the chain",
+			verdicts:   []Verdict{wa, ac, ac},
+			testCases:  []TestCase{{Group: 1}, {Group: 2}, {Group: 3}},
+			subtasks:   []modles.Subtask{{Group: 1, Points: 20}, {Group: 2, Points: 30, DependsOn: "1"}, {Group: 3, Points: 50, DependsOn: "2, x"}},
+			want:       0,
+			wantEarned: []float64{0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := JudgeResult{}
+			for i, v := range tt.verdicts {
+				result.Cases = append(result.Cases, TestResult{Case: i + 1, Verdict: v})
+			}
+
+			score(&result, tt.testCases, tt.subtasks)
+
+			if result.Score != tt.want {
+				t.Errorf("score = %v, want %v", result.Score, tt.want)
+			}
+			if len(result.Subtasks) != len(tt.wantEarned) {
+				t.Fatalf("got %d subtask results, want %d", len(result.Subtasks), len(tt.wantEarned))
+			}
+			for i, sr := range result.Subtasks {
+				if sr.Earned != tt.wantEarned[i] {
+					t.Errorf("group %d earned %v, want %v", sr.Group, sr.Earned, tt.wantEarned[i])
+				}
+			}
+		})
+	}
+}
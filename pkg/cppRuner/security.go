@@ -0,0 +1,119 @@
+package cppruner
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// seccompProfile is Docker's default profile (github.com/moby/profiles/seccomp v0.2.3): any
+// syscall it doesn't list fails with EPERM and clone can't create namespaces. The rules
+// only root capabilities enable are gone, and process tracing, io_uring, keyrings and
+// non-Unix sockets are taken out of the allow-list. Those and the mount, namespace,
+// kernel module and reboot calls kill the process, so the judge can report them.
+//
+//go:embed seccomp.json
+var seccompProfile []byte
+
+const (
+	// sandboxUser is nobody:nogroup, code never runs as root inside a sandbox
+	sandboxUser = "65534:65534"
+
+	// boxOwner is who pool containers run as. It installs the programs and results a test
+	// depends on where sandboxUser can't change them, and with every capability dropped it
+	// can do nothing else sandboxUser couldn't.
+	boxOwner = "0:0"
+
+	// compilePidsLimit leaves room for compiler drivers and their threads
+	compilePidsLimit = 256
+
+	// tmpSizeMB caps the writable /tmp, compilers keep their scratch files and caches there
+	tmpSizeMB = 256
+
+	// sigSYS is what the seccomp profile kills a process with
+	sigSYS = 31
+)
+
+var (
+	seccompPath     string
+	seccompPathErr  error
+	seccompPathOnce sync.Once
+)
+
+// seccompProfilePath returns JUDGE_SECCOMP_PROFILE or the embedded profile written to a temp file,
+// docker reads the profile from a path on the host
+func seccompProfilePath() (string, error) {
+	seccompPathOnce.Do(func() {
+		if path := os.Getenv("JUDGE_SECCOMP_PROFILE"); path != "" {
+			seccompPath = path
+			return
+		}
+
+		f, err := os.CreateTemp("", "codewar-seccomp-*.json")
+		if err != nil {
+			seccompPathErr = fmt.Errorf("failed to write seccomp profile: %v", err)
+			return
+		}
+		defer f.Close()
+
+		if _, err := f.Write(seccompProfile); err != nil {
+			seccompPathErr = fmt.Errorf("failed to write seccomp profile: %v", err)
+			return
+		}
+		seccompPath = f.Name()
+	})
+	return seccompPath, seccompPathErr
+}
+
+// securityArgs are the docker run flags every sandbox container gets: user (sandboxUser unless
+// the container only hands out work as sandboxUser), no capabilities, the seccomp profile,
+// a pids limit and a read-only root filesystem with a small writable /tmp
+func securityArgs(user string, pidsLimit int64) ([]string, error) {
+	profile, err := seccompProfilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		"--user", user,
+		"--cap-drop=ALL",
+		"--security-opt", "no-new-privileges",
+		"--security-opt", "seccomp=" + profile,
+		fmt.Sprintf("--pids-limit=%d", pidsLimit),
+		"--read-only",
+		"--tmpfs", fmt.Sprintf("/tmp:rw,exec,nosuid,size=%dm,mode=1777", tmpSizeMB),
+		"-e", "HOME=/tmp",
+		"-e", "TMPDIR=/tmp",
+	}, nil
+}
+
+// logSecurityViolation records who tripped the sandbox, these are worth a look by an admin
+func logSecurityViolation(userID uint, problemID uint, result TestResult) {
+	fmt.Printf("SECURITY VIOLATION: user %d, problem %d, test case %d: %s\n",
+		userID, problemID, result.Case, result.CheckerMessage)
+}
+
+// newInputDir creates the host directory a sandbox keeps input.txt in
+func newInputDir() (string, error) {
+	dir, err := os.MkdirTemp("", "codewar-input-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create input directory: %v", err)
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to create input directory: %v", err)
+	}
+	return dir, nil
+}
+
+// writeInputFile replaces dir/input.txt with a file nobody may write to
+func writeInputFile(dir, input string) error {
+	path := filepath.Join(dir, "input.txt")
+	os.Remove(path)
+	if err := os.WriteFile(path, []byte(input), 0444); err != nil {
+		return fmt.Errorf("failed to write test input: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package cppruner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+const (
+	// MaxStressRuns bounds the generated inputs of one stress test
+	MaxStressRuns = 1000
+
+	// stressBatch inputs are generated and judged at a time, so a counter-example early on
+	// doesn't wait for the whole run
+	stressBatch = 50
+
+	// generatorTimeout is what one call of the generator may take
+	generatorTimeout = 10 * time.Second
+)
+
+// generatorCompileCommand builds generator.cpp into ./generator
+var generatorCompileCommand = helperCompileCommand("generator")
+
+// StressReport is the outcome of a stress test, CounterExample is nil when the candidate
+// agreed with the reference on every generated input
+type StressReport struct {
+	ProblemID      uint                  `json:"problem_id"`
+	Language       string                `json:"language"`
+	Seed           int64                 `json:"seed"`
+	Runs           int                   `json:"runs"`
+	CounterExample *StressCounterExample `json:"counter_example,omitempty"`
+}
+
+// StressCounterExample is the first generated input the candidate got wrong
+type StressCounterExample struct {
+	Seed           int64       `json:"seed"`
+	Input          string      `json:"input"`
+	ExpectedOutput string      `json:"expected_output"`
+	Verdict        Verdict     `json:"verdict"`
+	CheckerMessage string      `json:"checker_message,omitempty"`
+	Diff           *OutputDiff `json:"diff,omitempty"`
+}
+
+// StressTest runs the problem's generator with seeds seed, seed+1, ... and judges code
+// against the reference solution's output on each input, up to runs inputs or the first
+// counter-example. The inputs only live in the report, nothing is stored.
+func StressTest(sandbox SandboxBackend, problemId uint, language string, code string, runs int, seed int64, db *database.Databse) (StressReport, error) {
+	report := StressReport{ProblemID: problemId, Language: language, Seed: seed}
+	if runs <= 0 || runs > MaxStressRuns {
+		return report, fmt.Errorf("runs must be between 1 and %d", MaxStressRuns)
+	}
+
+	problem, err := loadProblem(problemId, db)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch problem: %v", err)
+	}
+	switch {
+	case problem.Interactive:
+		return report, fmt.Errorf("problem %d is interactive, stress testing needs plain input files", problemId)
+	case problem.ReferenceSource == "":
+		return report, fmt.Errorf("problem %d has no reference solution", problemId)
+	case problem.GeneratorSource == "":
+		return report, fmt.Errorf("problem %d has no generator", problemId)
+	}
+
+	gen, err := newGenerator(sandbox, problem.GeneratorSource)
+	if err != nil {
+		return report, err
+	}
+	defer gen.cleanup()
+
+	for report.Runs < runs {
+		n := min(stressBatch, runs-report.Runs)
+		first := seed + int64(report.Runs)
+
+		inputs, err := gen.generate(first, n)
+		if err != nil {
+			return report, err
+		}
+
+		expected, err := referenceOutputs(sandbox, problem, inputs, first, db)
+		if err != nil {
+			return report, err
+		}
+
+		testCases := make([]TestCase, n)
+		for i := range inputs {
+			testCases[i] = TestCase{Input: inputs[i], ExpectedOutput: expected[i]}
+		}
+
+		result, err := judgeCode(sandbox, 0, problemId, language, code, testCases, StopOnFirstFailure, db, nil)
+		if err != nil {
+			return report, err
+		}
+
+		for i, c := range result.Cases {
+			if c.Verdict == VerdictAccepted || c.Verdict == VerdictSkipped {
+				continue
+			}
+			report.Runs += i + 1
+			report.CounterExample = &StressCounterExample{
+				Seed:           first + int64(i),
+				Input:          inputs[i],
+				ExpectedOutput: expected[i],
+				Verdict:        c.Verdict,
+				CheckerMessage: c.CheckerMessage,
+				Diff:           c.Diff,
+			}
+			return report, nil
+		}
+		report.Runs += n
+	}
+
+	return report, nil
+}
+
+// StressTestOn stress tests through judge, on the judge nodes when there are any, like StressTest does in-process
+func StressTestOn(judge JudgeBackend, problemId uint, language string, code string, runs int, seed int64) (StressReport, error) {
+	result, err := judge.Judge(JudgeJob{
+		ProblemID:  problemId,
+		Language:   language,
+		Code:       code,
+		Stress:     true,
+		StressRuns: runs,
+		StressSeed: seed,
+	}, nil)
+	if err != nil {
+		return StressReport{ProblemID: problemId, Language: language, Seed: seed}, err
+	}
+	if result.Stress == nil {
+		return StressReport{}, fmt.Errorf("judge returned no stress report")
+	}
+	return *result.Stress, nil
+}
+
+// RecordCounterExample stores the report's counter-example so it can be promoted to a test case later
+func RecordCounterExample(db *database.Databse, report StressReport) (*modles.CounterExample, error) {
+	if report.CounterExample == nil {
+		return nil, fmt.Errorf("the stress test found no counter-example")
+	}
+
+	ce := &modles.CounterExample{
+		ProblemID:      report.ProblemID,
+		Language:       report.Language,
+		Seed:           report.CounterExample.Seed,
+		Input:          report.CounterExample.Input,
+		ExpectedOutput: report.CounterExample.ExpectedOutput,
+		Verdict:        string(report.CounterExample.Verdict),
+	}
+	if err := database.SaveCounterExample(db, ce); err != nil {
+		return nil, err
+	}
+	return ce, nil
+}
+
+// referenceOutputs runs the problem's reference solution on inputs and returns its answers
+func referenceOutputs(sandbox SandboxBackend, problem *modles.ProblemPropaty, inputs []string, firstSeed int64, db *database.Databse) ([]string, error) {
+	cases, err := runReference(sandbox, problem, inputs, db)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]string, len(inputs))
+	for i, c := range cases {
+		if c.Verdict != VerdictOK {
+			return nil, fmt.Errorf("reference solution got %s on seed %d", c.Verdict, firstSeed+int64(i))
+		}
+		// Stdout is cut at maxStdoutBytes, a cut answer would make the comparison meaningless
+		if len(c.Stdout) > maxStdoutBytes {
+			return nil, fmt.Errorf("reference output on seed %d is over %d bytes, keep generated tests smaller", firstSeed+int64(i), maxStdoutBytes)
+		}
+		outputs[i] = c.Stdout
+	}
+	return outputs, nil
+}
+
+// generator is the problem's compiled input generator in its own sandbox
+type generator struct {
+	dir string
+	box Sandbox
+}
+
+func newGenerator(sandbox SandboxBackend, source string) (*generator, error) {
+	dir, err := os.MkdirTemp("", "generator_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	if err := writeHelperSource(dir, "generator", source); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	box, err := sandbox.NewSandbox(checkerImage, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	g := &generator{dir: dir, box: box}
+
+	acquireRunSlot()
+	_, err = box.Compile(generatorCompileCommand)
+	releaseRunSlot()
+	if err != nil {
+		g.cleanup()
+		return nil, fmt.Errorf("failed to compile generator: %v", err)
+	}
+	return g, nil
+}
+
+// generate runs ./generator once per seed from first to first+n-1 and returns what it printed
+func (g *generator) generate(first int64, n int) ([]string, error) {
+	seeds := make([]string, n)
+	for i := range seeds {
+		seeds[i] = strconv.FormatInt(first+int64(i), 10)
+	}
+
+	script := fmt.Sprintf(`mkdir -p out && find out -mindepth 1 -delete
+ulimit -f %d
+for s in %s; do
+  timeout -s KILL %.0f ./generator $s > out/$s.txt 2> out/generator_stderr.txt || { echo "generator failed on seed $s:"; cat out/generator_stderr.txt; exit 1; }
+done`, MaxRunInputBytes/1024*4, strings.Join(seeds, " "), generatorTimeout.Seconds())
+
+	limits := DefaultJudgeSettings().Limits
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(n+1)*generatorTimeout)
+	defer cancel()
+
+	acquireRunSlot()
+	out, err := g.box.Run(ctx, script, "", limits)
+	releaseRunSlot()
+	if err != nil {
+		return nil, fmt.Errorf("generator failed: %v: %s", err, truncate(string(out), maxStderrBytes))
+	}
+
+	inputs := make([]string, n)
+	for i, s := range seeds {
+		input, err := readResultFile(filepath.Join(g.dir, resultDir), s+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("generator left no input for seed %s: %v", s, err)
+		}
+		if len(input) > MaxRunInputBytes {
+			return nil, fmt.Errorf("generated input for seed %s is over %d bytes", s, MaxRunInputBytes)
+		}
+		inputs[i] = string(input)
+	}
+	return inputs, nil
+}
+
+func (g *generator) cleanup() {
+	g.box.Cleanup()
+	os.RemoveAll(g.dir)
+}
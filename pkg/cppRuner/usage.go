@@ -0,0 +1,148 @@
+package cppruner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// resultDir is where runScript leaves output, stderr and meta, relative to the work dir
+	resultDir = "out"
+
+	// sigXFSZ is raised when the program writes past the ulimit -f output cap
+	sigXFSZ = 25
+
+	// inputFile is where scripts find the test input, sandboxes without an /input mount set JUDGE_INPUT
+	inputFile = `"${JUDGE_INPUT:-/input/input.txt}"`
+)
+
+// runUsage is what the in-container wrapper script reports about one run
+type runUsage struct {
+	ExitCode  int
+	Signal    int
+	TimedOut  bool
+	OOMKilled bool
+	WallTime  time.Duration
+	CPUTime   time.Duration
+	MemoryKB  int64
+	Stderr    string
+
+	// SyscallBlocked and PidsLimitHit mean the sandbox stopped the program, a security violation
+	SyscallBlocked bool
+	PidsLimitHit   bool
+}
+
+// runScript wraps the run command so the container itself records exit status,
+// wall/CPU time and peak memory (from the container's cgroup, or $JUDGE_CGROUP when the
+// sandbox sets one) into out/meta.txt.
+// CPU time, OOM kills and pids limit hits are taken as deltas so a reused sandbox reports only this run.
+// Peak memory can't be, memory.peak only ever grows: sandboxes run every script in a fresh cgroup,
+// or like the pool reset the mark beforehand or append the run's own peak to meta.txt.
+// Interactive runs keep the script's stdin and stdout, the judge connects them to the interactor.
+func runScript(runCmd string, limits Limits, interactive bool) string {
+	run := fmt.Sprintf("timeout -s KILL %.3f %s < %s > out/output.txt 2> out/stderr.txt\ncode=$?", limits.Time().Seconds(), runCmd, inputFile)
+	if interactive {
+		run = fmt.Sprintf("timeout -s KILL %.3f %s 2> out/stderr.txt\ncode=$?", limits.Time().Seconds(), runCmd)
+	}
+
+	return fmt.Sprintf(`mkdir -p out && find out -mindepth 1 -delete
+cg=${JUDGE_CGROUP:-/sys/fs/cgroup}
+cpu_before=$(grep usage_usec $cg/cpu.stat 2>/dev/null | cut -d' ' -f2)
+oom_before=$(grep oom_kill $cg/memory.events 2>/dev/null | cut -d' ' -f2)
+pids_before=$(grep '^max ' $cg/pids.events 2>/dev/null | cut -d' ' -f2)
+ulimit -f %d
+ulimit -s %d
+start=$(date +%%s%%N)
+%s
+end=$(date +%%s%%N)
+{
+  echo "exit=$code"
+  echo "start=$start"
+  echo "end=$end"
+  echo "memory=$(cat $cg/memory.peak 2>/dev/null || cat $cg/memory/memory.max_usage_in_bytes 2>/dev/null)"
+  echo "cpu_before=$cpu_before"
+  echo "cpu_after=$(grep usage_usec $cg/cpu.stat 2>/dev/null | cut -d' ' -f2)"
+  echo "oom_before=$oom_before"
+  echo "oom_after=$(grep oom_kill $cg/memory.events 2>/dev/null | cut -d' ' -f2)"
+  echo "pids_before=$pids_before"
+  echo "pids_after=$(grep '^max ' $cg/pids.events 2>/dev/null | cut -d' ' -f2)"
+} > out/meta.txt
+exit 0`, limits.OutputKB*2, limits.StackMB*1024, run)
+}
+
+// readUsage parses meta.txt and stderr.txt written by runScript into dir, limit is the time limit the run had
+func readUsage(dir string, limit time.Duration) (runUsage, error) {
+	var usage runUsage
+
+	metaFile, err := readResultFile(dir, "meta.txt")
+	if err != nil {
+		return usage, fmt.Errorf("failed to open meta file: %v", err)
+	}
+
+	meta := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(metaFile))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			meta[key] = strings.TrimSpace(value)
+		}
+	}
+
+	usage.ExitCode, _ = strconv.Atoi(meta["exit"])
+	if usage.ExitCode > 128 {
+		usage.Signal = usage.ExitCode - 128
+	}
+
+	start, startErr := strconv.ParseInt(meta["start"], 10, 64)
+	end, endErr := strconv.ParseInt(meta["end"], 10, 64)
+	if startErr == nil && endErr == nil && end > start {
+		usage.WallTime = time.Duration(end - start)
+	}
+
+	if memory, err := strconv.ParseInt(meta["memory"], 10, 64); err == nil {
+		usage.MemoryKB = memory / 1024
+	}
+	cpuBefore, _ := strconv.ParseInt(meta["cpu_before"], 10, 64)
+	if cpuAfter, err := strconv.ParseInt(meta["cpu_after"], 10, 64); err == nil && cpuAfter > cpuBefore {
+		usage.CPUTime = time.Duration(cpuAfter-cpuBefore) * time.Microsecond
+	}
+	oomBefore, _ := strconv.Atoi(meta["oom_before"])
+	if oomAfter, err := strconv.Atoi(meta["oom_after"]); err == nil && oomAfter > oomBefore {
+		usage.OOMKilled = true
+	}
+
+	pidsBefore, _ := strconv.Atoi(meta["pids_before"])
+	if pidsAfter, err := strconv.Atoi(meta["pids_after"]); err == nil && pidsAfter > pidsBefore {
+		usage.PidsLimitHit = true
+	}
+	usage.SyscallBlocked = usage.Signal == sigSYS
+
+	// timeout -s KILL leaves 137 behind, which is only a TLE if we actually ran out the clock
+	usage.TimedOut = usage.ExitCode == 124 || (usage.Signal == 9 && !usage.OOMKilled && usage.WallTime >= limit)
+
+	if stderr, err := readResultFile(dir, "stderr.txt"); err == nil {
+		usage.Stderr = string(stderr)
+	}
+
+	return usage, nil
+}
+
+// readResultFile reads a file the sandbox left in dir. The program could have swapped it
+// for a link pointing at the host, so anything but a regular file is refused.
+func readResultFile(dir, name string) ([]byte, error) {
+	path := filepath.Join(dir, name)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	return os.ReadFile(path)
+}
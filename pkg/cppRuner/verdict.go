@@ -0,0 +1,118 @@
+package cppruner
+
+import "fmt"
+
+// Verdict is the outcome of a single test case or of a whole submission
+type Verdict string
+
+const (
+	VerdictAccepted     Verdict = "AC"
+	VerdictWrongAnswer  Verdict = "WA"
+	VerdictTimeLimit    Verdict = "TLE"
+	VerdictMemoryLimit  Verdict = "MLE"
+	VerdictRuntimeError Verdict = "RE"
+	VerdictOutputLimit  Verdict = "OLE"
+	VerdictCompileError Verdict = "CE"
+	// VerdictJudgeError means the problem's own checker failed, not the submission
+	VerdictJudgeError Verdict = "JE"
+	// VerdictSecurityViolation means the sandbox stopped the program: a blocked system call or too many processes
+	VerdictSecurityViolation Verdict = "SV"
+	// VerdictSkipped is a test case that never ran because an earlier one failed first
+	VerdictSkipped Verdict = "SKIP"
+	// VerdictOK is a custom run that finished within its limits, there was no answer to compare with
+	VerdictOK Verdict = "OK"
+)
+
+// maxStderrBytes caps how much of a program's stderr we keep per test case
+const maxStderrBytes = 1024
+
+// maxStdoutBytes caps the program output echoed back by custom runs
+const maxStdoutBytes = 64 * 1024
+
+// TestResult is the verdict and resource usage of one test case
+type TestResult struct {
+	Case      int     `json:"case"`
+	Verdict   Verdict `json:"verdict"`
+	Hidden    bool    `json:"hidden,omitempty"`
+	TimeMs    int64   `json:"time_ms"`
+	CPUTimeMs int64   `json:"cpu_time_ms"`
+	MemoryKB  int64   `json:"memory_kb"`
+	ExitCode  int     `json:"exit_code"`
+	Signal    int     `json:"signal,omitempty"`
+	Stderr    string  `json:"stderr,omitempty"`
+	// Stdout is only filled in for custom runs, submissions never echo program output
+	Stdout string `json:"stdout,omitempty"`
+	// CheckerMessage explains a WA when the problem uses a non-exact checker
+	CheckerMessage string `json:"checker_message,omitempty"`
+	// Diff locates the first mismatch of a WA on a visible test case
+	Diff *OutputDiff `json:"diff,omitempty"`
+}
+
+// CompileError is returned by JudgeCode when the submission does not build
+type CompileError struct {
+	// Output is the raw compiler output, template lines included
+	Output string
+	// Diagnostics are parsed from Output with lines counted in the player's code
+	Diagnostics []Diagnostic
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("compilation failed: %s", e.Details())
+}
+
+// Details is what a player is shown: the parsed diagnostics, or the raw output when none could be parsed
+func (e *CompileError) Details() string {
+	if len(e.Diagnostics) > 0 {
+		return formatDiagnostics(e.Diagnostics)
+	}
+	return e.Output
+}
+
+// overallVerdict follows Codeforces: the submission gets the verdict of the
+// first test case (in test order) that was not accepted. Custom runs without answers are OK.
+func overallVerdict(cases []TestResult) Verdict {
+	overall := VerdictAccepted
+	for _, c := range cases {
+		switch c.Verdict {
+		case VerdictAccepted, VerdictSkipped:
+		case VerdictOK:
+			overall = VerdictOK
+		default:
+			return c.Verdict
+		}
+	}
+	return overall
+}
+
+// Redacted strips everything but the verdict from hidden test cases so their
+// behaviour can't be probed through timings or stderr
+func (r JudgeResult) Redacted() JudgeResult {
+	cases := make([]TestResult, len(r.Cases))
+	for i, c := range r.Cases {
+		if c.Hidden {
+			c = TestResult{Case: c.Case, Verdict: c.Verdict, Hidden: true}
+		}
+		cases[i] = c
+	}
+	r.Cases = cases
+	return r
+}
+
+// PassedPrefix counts the test cases passed before the first one that wasn't. With
+// StopOnFirstFailure the tests after a failure may or may not have run depending on how
+// the lanes were scheduled, the prefix comes out the same every time.
+func (r JudgeResult) PassedPrefix() int {
+	for i, c := range r.Cases {
+		if c.Verdict != VerdictAccepted {
+			return i
+		}
+	}
+	return len(r.Cases)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "...(truncated)"
+}
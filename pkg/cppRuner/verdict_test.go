@@ -0,0 +1,30 @@
+package cppruner
+
+import "testing"
+
+func TestPassedPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		verdicts []Verdict
+		want     int
+	}{
+		{"all passed", []Verdict{VerdictAccepted, VerdictAccepted}, 2},
+		{"failure in the middle", []Verdict{VerdictAccepted, VerdictWrongAnswer, VerdictAccepted}, 1},
+		// Whether the third test ran depends on the lanes, it must not change the count
+		{"skipped after a failure", []Verdict{VerdictAccepted, VerdictTimeLimit, VerdictSkipped}, 1},
+		{"first test failed", []Verdict{VerdictRuntimeError, VerdictAccepted}, 0},
+		{"no tests", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result JudgeResult
+			for i, v := range tt.verdicts {
+				result.Cases = append(result.Cases, TestResult{Case: i + 1, Verdict: v})
+			}
+			if got := result.PassedPrefix(); got != tt.want {
+				t.Errorf("PassedPrefix() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
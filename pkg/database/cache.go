@@ -90,7 +90,7 @@ func (r *Redis) GetRandomproblemm() (*modles.ProblemPropaty, error) {
 	// Cache miss - fetch from database with all relations
 	fmt.Println("Cache MISS: Fetching full problems from database")
 	var problems []modles.ProblemPropaty
-	if err := r.db.Db.Preload("TestCases").Preload("Examples").Find(&problems).Error; err != nil {
+	if err := r.db.Db.Preload("TestCases").Preload("Examples").Preload("Templates").Preload("Subtasks").Find(&problems).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch problems from database: %v", err)
 	}
 
@@ -128,7 +128,7 @@ func (r *Redis) GetProblemById(problemId uint) (*modles.ProblemPropaty, error) {
 	// Cache miss - fetch from database with full data
 	fmt.Printf("Cache MISS: Fetching problem %d from database\n", problemId)
 	var problem modles.ProblemPropaty
-	if err := r.db.Db.Preload("TestCases").Preload("Examples").Where("id = ?", problemId).First(&problem).Error; err != nil {
+	if err := r.db.Db.Preload("TestCases").Preload("Examples").Preload("Templates").Preload("Subtasks").Where("id = ?", problemId).First(&problem).Error; err != nil {
 		return nil, fmt.Errorf("problem with id %d not found: %v", problemId, err)
 	}
 
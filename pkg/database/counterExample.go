@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+	"gorm.io/gorm"
+)
+
+// save a counter-example found by a stress test
+func SaveCounterExample(db *Databse, ce *modles.CounterExample) error {
+	if err := db.Db.Create(ce).Error; err != nil {
+		return fmt.Errorf("failed to save counter-example: %v", err)
+	}
+	return nil
+}
+
+// turn a counter-example into a hidden test case of its problem in subtask group,
+// promoting twice is an error
+func PromoteCounterExample(db *Databse, id uint, group int) (*modles.TestCaesPropaty, error) {
+	var testCase modles.TestCaesPropaty
+
+	err := db.Db.Transaction(func(tx *gorm.DB) error {
+		var ce modles.CounterExample
+		if err := tx.Where("id = ?", id).First(&ce).Error; err != nil {
+			return fmt.Errorf("counter-example %d not found: %v", id, err)
+		}
+		if ce.PromotedTestID != 0 {
+			return fmt.Errorf("counter-example %d is already test case %d", id, ce.PromotedTestID)
+		}
+
+		testCase = modles.TestCaesPropaty{
+			ProblemID:      ce.ProblemID,
+			Input:          ce.Input,
+			ExpectedOutput: ce.ExpectedOutput,
+			Group:          group,
+		}
+		if err := tx.Create(&testCase).Error; err != nil {
+			return fmt.Errorf("failed to create test case: %v", err)
+		}
+		return tx.Model(&ce).Update("promoted_test_id", testCase.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The judge reads test cases through the cache
+	if db.Cache != nil {
+		db.Cache.ClearproblemCache(testCase.ProblemID)
+	}
+	return &testCase, nil
+}
@@ -55,12 +55,9 @@ func ConectToDb(db *Databse) error {
 
 	db.Db = conn
 
-	// Auto migrate the schema
-	err = db.Db.AutoMigrate(&modles.ProblemPropaty{}, &modles.TestCaesPropaty{}, &modles.User{}, &modles.RefreshToken{}, &modles.Subscription{}, &modles.GameUsage{}, &modles.Example{})
-	if err != nil {
-		return fmt.Errorf("failed to auto migrate the database: %v", err)
+	if err := Migrate(db); err != nil {
+		return err
 	}
-	fmt.Println("Database auto migrate successfully")
 
 	// Initialize Redis cache
 	db.Cache = NewServerChace(db)
@@ -71,6 +68,24 @@ func ConectToDb(db *Databse) error {
 	return nil
 }
 
+// Migrate brings the schema and the data it depends on up to date
+func Migrate(db *Databse) error {
+	// Auto migrate the schema
+	err := db.Db.AutoMigrate(&modles.ProblemPropaty{}, &modles.TestCaesPropaty{}, &modles.User{}, &modles.RefreshToken{}, &modles.Subscription{}, &modles.GameUsage{}, &modles.Example{}, &modles.LanguageTemplate{}, &modles.Subtask{}, &modles.CounterExample{}, &modles.Match{}, &modles.MatchSubmission{}, &modles.SimilarityFlag{}, &modles.Hack{}, &modles.RatingHistory{})
+	if err != nil {
+		return fmt.Errorf("failed to auto migrate the database: %v", err)
+	}
+	fmt.Println("Database auto migrate successfully")
+
+	// Users from before ratings started at 1200 still have 0
+	if backfilled, err := BackfillInitialRatings(db); err != nil {
+		return err
+	} else if backfilled > 0 {
+		fmt.Printf("Gave %d unrated players the initial rating\n", backfilled)
+	}
+	return nil
+}
+
 func GetDb(d *Databse) *gorm.DB {
 	return d.Db
 }
@@ -84,7 +99,7 @@ func GetRandomProblem(db *Databse) (*modles.ProblemPropaty, error) {
 
 	//fall back to the db
 	var problems []modles.ProblemPropaty
-	if err := db.Db.Preload("TestCases").Preload("Examples").Find(&problems).Error; err != nil {
+	if err := db.Db.Preload("TestCases").Preload("Examples").Preload("Templates").Preload("Subtasks").Find(&problems).Error; err != nil {
 		return nil, err
 	}
 	if len(problems) == 0 {
@@ -138,6 +153,90 @@ using namespace std;`,
     vector<int> result = twoSum(nums, target);
     cout << result[0] << " " << result[1] << endl;
 
+    return 0;
+}`,
+			// Any pair of indices adding up to target is a valid answer
+			Checker: "custom",
+			CheckerSource: `#include <bits/stdc++.h>
+using namespace std;
+
+// usage: checker input output answer, exit 0 = ok, 1 = wrong answer, 3 = fail
+int main(int argc, char* argv[]) {
+    if (argc < 4) return 3;
+    ifstream in(argv[1]), out(argv[2]);
+
+    int n;
+    in >> n;
+    vector<long long> nums(n);
+    for (auto& x : nums) in >> x;
+    long long target;
+    in >> target;
+
+    long long i, j;
+    if (!(out >> i >> j)) {
+        cerr << "expected two indices";
+        return 1;
+    }
+    if (i < 0 || j < 0 || i >= n || j >= n || i == j) {
+        cerr << "indices out of range or equal";
+        return 1;
+    }
+    if (nums[i] + nums[j] != target) {
+        cerr << "nums[i] + nums[j] does not equal target";
+        return 1;
+    }
+    string extra;
+    if (out >> extra) {
+        cerr << "extra output after the indices";
+        return 1;
+    }
+    return 0;
+}`,
+			Templates: []modles.LanguageTemplate{
+				{
+					Language:  "python3",
+					HaderFile: `from typing import List`,
+					FuncBody: `def two_sum(nums: List[int], target: int) -> List[int]:
+    # Your code here
+    pass`,
+					MainFunc: `if __name__ == "__main__":
+    n = int(input())
+    nums = list(map(int, input().split()))
+    target = int(input())
+    result = two_sum(nums, target)
+    print(result[0], result[1])`,
+				},
+			},
+			// Expected outputs stay literal so the problem can be judged right away, revalidation
+			// at startup regenerates them from the reference solution in the background
+			ReferenceSource: `vector<int> twoSum(vector<int>& nums, int target) {
+    unordered_map<int, int> seen;
+    for (int i = 0; i < (int)nums.size(); i++) {
+        auto it = seen.find(target - nums[i]);
+        if (it != seen.end()) return {it->second, i};
+        seen[nums[i]] = i;
+    }
+    return {-1, -1};
+}`,
+			// Hacks must keep to the statement: 2 <= n <= 10^4, |values| <= 10^9 and exactly one answer
+			ValidatorSource: `#include <bits/stdc++.h>
+using namespace std;
+int main() {
+    long long n, target;
+    if (!(cin >> n) || n < 2 || n > 10000) { cerr << "n must be between 2 and 10^4"; return 1; }
+    vector<long long> a(n);
+    for (auto& x : a)
+        if (!(cin >> x) || llabs(x) > 1000000000) { cerr << "values must be at most 10^9 in absolute value"; return 1; }
+    if (!(cin >> target) || llabs(target) > 1000000000) { cerr << "target must be at most 10^9 in absolute value"; return 1; }
+    string extra;
+    if (cin >> extra) { cerr << "unexpected trailing input"; return 1; }
+    int answers = 0;
+    map<long long, int> seen;
+    for (auto x : a) {
+        if (seen.count(target - x)) answers += seen[target - x];
+        seen[x]++;
+    }
+    if (answers != 1) { cerr << "the input must have exactly one answer"; return 1; }
     return 0;
 }`,
 			TestCases: []modles.TestCaesPropaty{
@@ -182,25 +281,54 @@ using namespace std;`,
     cout << result << endl;
 
     return 0;
+}`,
+			Templates: []modles.LanguageTemplate{
+				{
+					Language: "python3",
+					FuncBody: `def reverse(x: int) -> int:
+    # Your code here
+    pass`,
+					MainFunc: `if __name__ == "__main__":
+    x = int(input())
+    print(reverse(x))`,
+				},
+			},
+			ReferenceSource: `int reverse(int x) {
+    long long r = 0;
+    while (x != 0) {
+        r = r * 10 + x % 10;
+        x /= 10;
+    }
+    return (r < INT_MIN || r > INT_MAX) ? 0 : (int)r;
 }`,
 			TestCases: []modles.TestCaesPropaty{
 				{
 					Input:          "123",
 					ExpectedOutput: "321",
+					Group:          1,
 				},
 				{
 					Input:          "-123",
 					ExpectedOutput: "-321",
+					Group:          2,
 				},
 				{
 					Input:          "120",
 					ExpectedOutput: "21",
+					Group:          3,
 				},
 				{
 					Input:          "0",
 					ExpectedOutput: "0",
+					Group:          1,
 				},
 			},
+			// Positives first, negatives only count once positives work, trailing zeros on their own
+			Subtasks: []modles.Subtask{
+				{Group: 1, Points: 40, Policy: "min"},
+				{Group: 2, Points: 30, Policy: "min", DependsOn: "1"},
+				{Group: 3, Points: 30, Policy: "sum"},
+			},
 		},
 	}
 
@@ -0,0 +1,37 @@
+// Package dbtest gives tests a throwaway database with the server's schema, so code that
+// talks to the database can be tested without a Postgres server
+package dbtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// New opens a migrated SQLite database in a temporary directory, closed when the test ends.
+// There is no cache, everything reads the database like a server without Redis.
+func New(t testing.TB) *database.Databse {
+	t.Helper()
+
+	// Transactions take the write lock up front and wait for each other instead of failing
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatalf("failed to get test database instance: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db := &database.Databse{Db: conn}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+	"gorm.io/gorm"
+)
+
+// save a judged hack and give its points to the hacker, a submission can only be
+// hacked successfully once
+func SaveHack(db *Databse, hack *modles.Hack) error {
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if hack.Outcome == modles.HackSuccess {
+			var count int64
+			if err := tx.Model(&modles.Hack{}).Where("submission_id = ? AND outcome = ?", hack.SubmissionID, modles.HackSuccess).
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check earlier hacks: %v", err)
+			}
+			if count > 0 {
+				return fmt.Errorf("submission %d was already hacked", hack.SubmissionID)
+			}
+		}
+
+		if err := tx.Create(hack).Error; err != nil {
+			return fmt.Errorf("failed to save hack: %v", err)
+		}
+		if hack.Points == 0 {
+			return nil
+		}
+		if err := tx.Model(&modles.User{}).Where("id = ?", hack.HackerID).
+			UpdateColumn("hack_points", gorm.Expr("hack_points + ?", hack.Points)).Error; err != nil {
+			return fmt.Errorf("failed to update hack points of user %d: %v", hack.HackerID, err)
+		}
+		return nil
+	})
+}
+
+// check whether a submission was already hacked successfully
+func SubmissionHacked(db *Databse, submissionID uint) (bool, error) {
+	var count int64
+	err := db.Db.Model(&modles.Hack{}).Where("submission_id = ? AND outcome = ?", submissionID, modles.HackSuccess).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check hacks of submission %d: %v", submissionID, err)
+	}
+	return count > 0, nil
+}
+
+// list hacks with an outcome, newest first, an empty outcome lists all of them
+func ListHacks(db *Databse, outcome string, limit, offset int) ([]modles.Hack, int64, error) {
+	query := db.Db.Model(&modles.Hack{})
+	if outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count hacks: %v", err)
+	}
+
+	var hacks []modles.Hack
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&hacks).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch hacks: %v", err)
+	}
+	return hacks, total, nil
+}
+
+// turn a successful hack into a hidden test case of its problem in subtask group,
+// promoting twice is an error
+func PromoteHack(db *Databse, id uint, group int) (*modles.TestCaesPropaty, error) {
+	var testCase modles.TestCaesPropaty
+
+	err := db.Db.Transaction(func(tx *gorm.DB) error {
+		var hack modles.Hack
+		if err := tx.Where("id = ?", id).First(&hack).Error; err != nil {
+			return fmt.Errorf("hack %d not found: %v", id, err)
+		}
+		if hack.Outcome != modles.HackSuccess {
+			return fmt.Errorf("hack %d was not successful", id)
+		}
+		if hack.PromotedTestID != 0 {
+			return fmt.Errorf("hack %d is already test case %d", id, hack.PromotedTestID)
+		}
+
+		testCase = modles.TestCaesPropaty{
+			ProblemID:      hack.ProblemID,
+			Input:          hack.Input,
+			ExpectedOutput: hack.ExpectedOutput,
+			Group:          group,
+		}
+		if err := tx.Create(&testCase).Error; err != nil {
+			return fmt.Errorf("failed to create test case: %v", err)
+		}
+		return tx.Model(&hack).Update("promoted_test_id", testCase.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The judge reads test cases through the cache
+	if db.Cache != nil {
+		db.Cache.ClearproblemCache(testCase.ProblemID)
+	}
+	return &testCase, nil
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	judgeQueueKey = "judge_queue"
+	// Every server parks the jobs it works on in its own processing list and keeps its
+	// heartbeat key alive, judgeServersKey lists the servers so others can find their jobs
+	judgeProcessingKey  = "judge_processing_"
+	judgeHeartbeatKey   = "judge_heartbeat_"
+	judgeServersKey     = "judge_servers"
+	submissionStatusKey = "submission_status_"
+	submissionChannel   = "submission_status"
+)
+
+// push a judge job to the head of the queue
+func (r *Redis) EnqueueJudgeJob(job []byte) error {
+	return r.client.LPush(r.ctx, judgeQueueKey, job).Err()
+}
+
+// pop the oldest judge job and park it in server's processing list until it is acked,
+// returns nil when nothing arrived before the timeout
+func (r *Redis) DequeueJudgeJob(server string, timeout time.Duration) ([]byte, error) {
+	job, err := r.client.BRPopLPush(r.ctx, judgeQueueKey, judgeProcessingKey+server, timeout).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return job, err
+}
+
+// remove a finished job from server's processing list
+func (r *Redis) AckJudgeJob(server string, job []byte) error {
+	return r.client.LRem(r.ctx, judgeProcessingKey+server, 1, job).Err()
+}
+
+// mark server as alive for ttl, its jobs are left alone until the heartbeat expires
+func (r *Redis) JudgeHeartbeat(server string, ttl time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.SAdd(r.ctx, judgeServersKey, server)
+	pipe.Set(r.ctx, judgeHeartbeatKey+server, 1, ttl)
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// move the jobs of servers whose heartbeat expired back to the queue, the jobs of
+// servers still beating are theirs even when they take long
+func (r *Redis) RequeueOrphanedJobs() (int, error) {
+	servers, err := r.client.SMembers(r.ctx, judgeServersKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, server := range servers {
+		alive, err := r.client.Exists(r.ctx, judgeHeartbeatKey+server).Result()
+		if err != nil {
+			return moved, err
+		}
+		if alive > 0 {
+			continue
+		}
+
+		// Each job moves atomically, servers recovering the same list at once can't duplicate it
+		for {
+			err := r.client.RPopLPush(r.ctx, judgeProcessingKey+server, judgeQueueKey).Err()
+			if err == redis.Nil {
+				break
+			}
+			if err != nil {
+				return moved, err
+			}
+			moved++
+		}
+		if err := r.client.SRem(r.ctx, judgeServersKey, server).Err(); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+// store the latest status of a submission for polling
+func (r *Redis) SaveSubmissionStatus(id string, status []byte, ttl time.Duration) error {
+	return r.client.Set(r.ctx, submissionStatusKey+id, status, ttl).Err()
+}
+
+// get the latest status of a submission, nil if unknown or expired
+func (r *Redis) GetSubmissionStatus(id string) ([]byte, error) {
+	status, err := r.client.Get(r.ctx, submissionStatusKey+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return status, err
+}
+
+// broadcast a status change to every server
+func (r *Redis) PublishSubmissionStatus(status []byte) error {
+	return r.client.Publish(r.ctx, submissionChannel, status).Err()
+}
+
+// listen for status changes published by any server
+func (r *Redis) SubscribeSubmissionStatus() <-chan []byte {
+	sub := r.client.Subscribe(r.ctx, submissionChannel)
+	out := make(chan []byte)
+
+	go func() {
+		defer close(out)
+		for msg := range sub.Channel() {
+			out <- []byte(msg.Payload)
+		}
+	}()
+
+	return out
+}
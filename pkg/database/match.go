@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// save a match when the players are paired
+func CreateMatch(db *Databse, match *modles.Match) error {
+	if err := db.Db.Create(match).Error; err != nil {
+		return fmt.Errorf("failed to create match: %v", err)
+	}
+	return nil
+}
+
+// save a submission made during a match
+func SaveMatchSubmission(db *Databse, sub *modles.MatchSubmission) error {
+	if err := db.Db.Create(sub).Error; err != nil {
+		return fmt.Errorf("failed to save match submission: %v", err)
+	}
+	return nil
+}
+
+// store the judge's verdict of a match submission
+func UpdateMatchSubmissionResult(db *Databse, id uint, verdict string, score float64) error {
+	err := db.Db.Model(&modles.MatchSubmission{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"verdict": verdict, "score": score}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update match submission %d: %v", id, err)
+	}
+	return nil
+}
+
+// undo a match result: both rating changes are taken back and the match is marked reverted,
+// reverting twice is an error
+func RevertMatch(db *Databse, id uint) (*modles.Match, error) {
+	var match modles.Match
+
+	err := db.Db.Transaction(func(tx *gorm.DB) error {
+		// Locked like in RecordMatchResult so two reverts can't both take the ratings back
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&match).Error; err != nil {
+			return fmt.Errorf("match %d not found: %v", id, err)
+		}
+		if match.Reverted {
+			return fmt.Errorf("match %d is already reverted", id)
+		}
+		return revertMatch(tx, &match)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// take back the rating changes of a match and mark it reverted, the caller holds the match row lock
+func revertMatch(tx *gorm.DB, match *modles.Match) error {
+	// A decided match also counted as a game played by both players
+	games := 0
+	if match.Result != "" {
+		games = 1
+	}
+	for userID, delta := range map[uint]int{match.Player1ID: match.Player1RatingDelta, match.Player2ID: match.Player2RatingDelta} {
+		if delta == 0 && games == 0 {
+			continue
+		}
+		if err := tx.Model(&modles.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"rating":       gorm.Expr("rating - ?", delta),
+			"games_played": gorm.Expr("games_played - ?", games),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to revert rating of user %d: %v", userID, err)
+		}
+	}
+	// The history rows are soft deleted so the change can still be looked up
+	if err := tx.Where("match_id = ?", match.ID).Delete(&modles.RatingHistory{}).Error; err != nil {
+		return fmt.Errorf("failed to revert rating history of match %d: %v", match.ID, err)
+	}
+
+	now := time.Now()
+	match.Reverted = true
+	match.RevertedAt = &now
+	return tx.Model(match).Updates(map[string]interface{}{"reverted": true, "reverted_at": now}).Error
+}
+
+// get one match by id
+func GetMatch(db *Databse, id uint) (*modles.Match, error) {
+	var match modles.Match
+	if err := db.Db.Where("id = ?", id).First(&match).Error; err != nil {
+		return nil, fmt.Errorf("match %d not found: %v", id, err)
+	}
+	return &match, nil
+}
+
+// get the last accepted submission of a player in a match
+func LatestAcceptedSubmission(db *Databse, matchID, userID uint) (*modles.MatchSubmission, error) {
+	var sub modles.MatchSubmission
+	err := db.Db.Where("match_id = ? AND user_id = ? AND verdict = ?", matchID, userID, "AC").
+		Order("id DESC").First(&sub).Error
+	if err != nil {
+		return nil, fmt.Errorf("no accepted submission of user %d in match %d: %v", userID, matchID, err)
+	}
+	return &sub, nil
+}
+
+// list the matches a user played, newest first
+func ListUserMatches(db *Databse, userID uint, limit, offset int) ([]modles.Match, int64, error) {
+	query := db.Db.Model(&modles.Match{}).Where("player1_id = ? OR player2_id = ?", userID, userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count matches: %v", err)
+	}
+
+	var matches []modles.Match
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&matches).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch matches: %v", err)
+	}
+	return matches, total, nil
+}
+
+// list the submissions of a match in the order they were made
+func ListMatchSubmissions(db *Databse, matchID uint) ([]modles.MatchSubmission, error) {
+	var subs []modles.MatchSubmission
+	if err := db.Db.Where("match_id = ?", matchID).Order("id").Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch submissions of match %d: %v", matchID, err)
+	}
+	return subs, nil
+}
+
+// list the hacks made after a match in the order they were made
+func ListMatchHacks(db *Databse, matchID uint) ([]modles.Hack, error) {
+	var hacks []modles.Hack
+	if err := db.Db.Where("match_id = ?", matchID).Order("id").Find(&hacks).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch hacks of match %d: %v", matchID, err)
+	}
+	return hacks, nil
+}
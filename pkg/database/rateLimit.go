@@ -0,0 +1,19 @@
+package database
+
+import "time"
+
+const rateLimitKey = "rate_limit_"
+
+// count one more hit in the current window of key, the counter expires with the window
+func (r *Redis) IncrementRateCounter(key string, window time.Duration) (int64, error) {
+	count, err := r.client.Incr(r.ctx, rateLimitKey+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := r.client.Expire(r.ctx, rateLimitKey+key, window).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
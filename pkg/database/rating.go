@@ -0,0 +1,224 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+	"github.com/iAmImran007/Code_War/pkg/rating"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// decide a match and rate both players in one transaction: winnerID is 0 for a draw, result is
+// modles.MatchWin, MatchDraw or MatchForfeit and endReason what ended it. The rating changes go
+// on the match and into the rating history, deciding a match twice is an error.
+func RecordMatchResult(db *Databse, matchID, winnerID uint, result, endReason string) (*modles.Match, error) {
+	var match modles.Match
+
+	err := db.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", matchID).First(&match).Error; err != nil {
+			return fmt.Errorf("match %d not found: %v", matchID, err)
+		}
+		if match.Result != "" || match.Reverted {
+			return fmt.Errorf("match %d is already decided", matchID)
+		}
+
+		var users []modles.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint{match.Player1ID, match.Player2ID}).Find(&users).Error; err != nil {
+			return fmt.Errorf("failed to fetch players of match %d: %v", matchID, err)
+		}
+		players := map[uint]*rating.Player{}
+		for _, u := range users {
+			players[u.ID] = &rating.Player{Rating: u.Rating, Games: u.GamesPlayed}
+		}
+		if players[match.Player1ID] == nil || players[match.Player2ID] == nil {
+			return fmt.Errorf("players of match %d not found", matchID)
+		}
+
+		history, err := rateMatch(&match, winnerID, result, players)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range []uint{match.Player1ID, match.Player2ID} {
+			if err := tx.Model(&modles.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"rating":       players[id].Rating,
+				"games_played": players[id].Games,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update rating of user %d: %v", id, err)
+			}
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return fmt.Errorf("failed to save rating history: %v", err)
+		}
+		now := time.Now()
+		match.EndedAt = &now
+		match.EndReason = endReason
+		return tx.Model(&match).Updates(map[string]interface{}{
+			"winner_id":            match.WinnerID,
+			"result":               match.Result,
+			"ended_at":             now,
+			"end_reason":           endReason,
+			"player1_rating_delta": match.Player1RatingDelta,
+			"player2_rating_delta": match.Player2RatingDelta,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// give players who never played a rated match the initial rating. Accounts created while the
+// rating column still defaulted to 0 kept that 0, which would rate them against an opponent
+// 1200 points above. Returns the number of players updated, running it again changes nothing.
+func BackfillInitialRatings(db *Databse) (int64, error) {
+	res := db.Db.Model(&modles.User{}).
+		Where("games_played = ? AND rating = ?", 0, 0).
+		Update("rating", rating.InitialRating)
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to backfill initial ratings: %v", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// replay every decided match that wasn't reverted in the order they ended, from the initial rating:
+// ratings, games played, the deltas on the matches and the rating history are all rebuilt.
+// Returns the number of matches replayed.
+func RecomputeRatings(db *Databse) (int, error) {
+	var replayed int
+
+	err := db.Db.Transaction(func(tx *gorm.DB) error {
+		var matches []modles.Match
+		if err := tx.Where("result <> '' AND reverted = ?", false).Order("COALESCE(ended_at, created_at), id").Find(&matches).Error; err != nil {
+			return fmt.Errorf("failed to fetch matches: %v", err)
+		}
+
+		players := map[uint]*rating.Player{}
+		var history []modles.RatingHistory
+		for i := range matches {
+			match := &matches[i]
+			for _, id := range []uint{match.Player1ID, match.Player2ID} {
+				if players[id] == nil {
+					players[id] = &rating.Player{Rating: rating.InitialRating}
+				}
+			}
+			rows, err := rateMatch(match, match.WinnerID, match.Result, players)
+			if err != nil {
+				return err
+			}
+			history = append(history, rows...)
+		}
+
+		if err := tx.Model(&modles.User{}).Where("1 = 1").Updates(map[string]interface{}{
+			"rating":       rating.InitialRating,
+			"games_played": 0,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to reset ratings: %v", err)
+		}
+		for id, p := range players {
+			if err := tx.Model(&modles.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"rating":       p.Rating,
+				"games_played": p.Games,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update rating of user %d: %v", id, err)
+			}
+		}
+
+		if err := tx.Unscoped().Where("1 = 1").Delete(&modles.RatingHistory{}).Error; err != nil {
+			return fmt.Errorf("failed to clear rating history: %v", err)
+		}
+		if len(history) > 0 {
+			if err := tx.CreateInBatches(&history, 500).Error; err != nil {
+				return fmt.Errorf("failed to save rating history: %v", err)
+			}
+		}
+
+		for _, match := range matches {
+			if err := tx.Model(&modles.Match{}).Where("id = ?", match.ID).Updates(map[string]interface{}{
+				"player1_rating_delta": match.Player1RatingDelta,
+				"player2_rating_delta": match.Player2RatingDelta,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update match %d: %v", match.ID, err)
+			}
+		}
+
+		replayed = len(matches)
+		return nil
+	})
+	return replayed, err
+}
+
+// list a player's rating history, newest first
+func ListRatingHistory(db *Databse, userID uint, limit, offset int) ([]modles.RatingHistory, int64, error) {
+	query := db.Db.Model(&modles.RatingHistory{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count rating history: %v", err)
+	}
+
+	var history []modles.RatingHistory
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&history).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch rating history: %v", err)
+	}
+	return history, total, nil
+}
+
+// rateMatch applies a match result to players, filling in the match's result and deltas,
+// and returns the history rows of both players
+func rateMatch(match *modles.Match, winnerID uint, result string, players map[uint]*rating.Player) ([]modles.RatingHistory, error) {
+	score := rating.Draw
+	switch {
+	case result == modles.MatchDraw:
+		winnerID = 0
+	case result != modles.MatchWin && result != modles.MatchForfeit:
+		return nil, fmt.Errorf("unknown match result %q", result)
+	case winnerID == match.Player1ID:
+		score = rating.Win
+	case winnerID == match.Player2ID:
+		score = rating.Loss
+	default:
+		return nil, fmt.Errorf("user %d did not play match %d", winnerID, match.ID)
+	}
+
+	p1, p2 := players[match.Player1ID], players[match.Player2ID]
+	before1, before2 := p1.Rating, p2.Rating
+	d1, d2 := rating.Deltas(*p1, *p2, score)
+
+	p1.Rating += d1
+	p2.Rating += d2
+	p1.Games++
+	p2.Games++
+
+	match.WinnerID = winnerID
+	match.Result = result
+	match.Player1RatingDelta = d1
+	match.Player2RatingDelta = d2
+
+	row := func(userID, opponentID uint, before, delta, opponentBefore int) modles.RatingHistory {
+		outcome := "draw"
+		if winnerID == userID {
+			outcome = "win"
+		} else if winnerID != 0 {
+			outcome = "loss"
+		}
+		return modles.RatingHistory{
+			UserID:         userID,
+			MatchID:        match.ID,
+			OpponentID:     opponentID,
+			Result:         outcome,
+			Forfeit:        result == modles.MatchForfeit,
+			RatingBefore:   before,
+			RatingAfter:    before + delta,
+			Delta:          delta,
+			OpponentRating: opponentBefore,
+		}
+	}
+	return []modles.RatingHistory{
+		row(match.Player1ID, match.Player2ID, before1, d1, before2),
+		row(match.Player2ID, match.Player1ID, before2, d2, before1),
+	}, nil
+}
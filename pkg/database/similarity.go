@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// save flags raised by the similarity check
+func SaveSimilarityFlags(db *Databse, flags []modles.SimilarityFlag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	if err := db.Db.Create(&flags).Error; err != nil {
+		return fmt.Errorf("failed to save similarity flags: %v", err)
+	}
+	return nil
+}
+
+// list flags in a review state, highest score first, an empty status lists all of them
+func ListSimilarityFlags(db *Databse, status string, limit, offset int) ([]modles.SimilarityFlag, int64, error) {
+	query := db.Db.Model(&modles.SimilarityFlag{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count similarity flags: %v", err)
+	}
+
+	var flags []modles.SimilarityFlag
+	if err := query.Order("score DESC, id").Limit(limit).Offset(offset).Find(&flags).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch similarity flags: %v", err)
+	}
+	return flags, total, nil
+}
+
+// get one flag by id
+func GetSimilarityFlag(db *Databse, id uint) (*modles.SimilarityFlag, error) {
+	var flag modles.SimilarityFlag
+	if err := db.Db.Where("id = ?", id).First(&flag).Error; err != nil {
+		return nil, fmt.Errorf("similarity flag %d not found: %v", id, err)
+	}
+	return &flag, nil
+}
+
+// close a pending flag as dismissed or reverted by reviewer
+func ResolveSimilarityFlag(db *Databse, id uint, status string, reviewer uint) error {
+	result := db.Db.Model(&modles.SimilarityFlag{}).Where("id = ? AND status = ?", id, modles.FlagPending).
+		Updates(map[string]interface{}{"status": status, "reviewed_by": reviewer})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update similarity flag %d: %v", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("similarity flag %d is not pending", id)
+	}
+	return nil
+}
+
+// confirm a pending flag by reverting its match, a match reverted already counts as done, the
+// other pending flags of the match are closed with it since there is nothing left to revert
+func RevertSimilarityFlag(db *Databse, id uint, reviewer uint) (*modles.Match, error) {
+	var match modles.Match
+
+	err := db.Db.Transaction(func(tx *gorm.DB) error {
+		var flag modles.SimilarityFlag
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&flag).Error; err != nil {
+			return fmt.Errorf("similarity flag %d not found: %v", id, err)
+		}
+		if flag.Status != modles.FlagPending {
+			return fmt.Errorf("similarity flag %d is not pending", id)
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", flag.MatchID).First(&match).Error; err != nil {
+			return fmt.Errorf("match %d not found: %v", flag.MatchID, err)
+		}
+		if !match.Reverted {
+			if err := revertMatch(tx, &match); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&modles.SimilarityFlag{}).Where("match_id = ? AND status = ?", match.ID, modles.FlagPending).
+			Updates(map[string]interface{}{"status": modles.FlagReverted, "reviewed_by": reviewer}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update similarity flags of match %d: %v", match.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
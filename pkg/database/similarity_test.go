@@ -0,0 +1,68 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/database/dbtest"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+func TestRevertSimilarityFlag(t *testing.T) {
+	db := dbtest.New(t)
+
+	winner := modles.User{Email: "winner@example.com", Password: "x", Rating: 1516, GamesPlayed: 1}
+	loser := modles.User{Email: "loser@example.com", Password: "x", Rating: 1484, GamesPlayed: 1}
+	for _, user := range []*modles.User{&winner, &loser} {
+		if err := db.Db.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	match := modles.Match{Player1ID: winner.ID, Player2ID: loser.ID, WinnerID: winner.ID, Result: "win", Player1RatingDelta: 16, Player2RatingDelta: -16}
+	if err := db.Db.Create(&match).Error; err != nil {
+		t.Fatalf("failed to create match: %v", err)
+	}
+	flags := []modles.SimilarityFlag{
+		{MatchID: match.ID, UserID: winner.ID, Score: 0.9},
+		{MatchID: match.ID, UserID: winner.ID, Score: 0.85},
+	}
+	if err := database.SaveSimilarityFlags(db, flags); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := database.RevertSimilarityFlag(db, flags[0].ID, 99)
+	if err != nil || !reverted.Reverted {
+		t.Fatalf("revert = %+v, %v, want a reverted match", reverted, err)
+	}
+
+	// Every pending flag of the match is closed by the one revert
+	for _, flag := range flags {
+		got, _ := database.GetSimilarityFlag(db, flag.ID)
+		if got.Status != modles.FlagReverted || got.ReviewedBy != 99 {
+			t.Errorf("flag %d = %s by %d, want %s by 99", flag.ID, got.Status, got.ReviewedBy, modles.FlagReverted)
+		}
+	}
+	if _, err := database.RevertSimilarityFlag(db, flags[1].ID, 99); err == nil {
+		t.Error("reverting a closed flag succeeded")
+	}
+
+	// A flag raised after the match was reverted is closed without taking the ratings back again
+	late := []modles.SimilarityFlag{{MatchID: match.ID, UserID: loser.ID, Score: 0.8}}
+	if err := database.SaveSimilarityFlags(db, late); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.RevertSimilarityFlag(db, late[0].ID, 99); err != nil {
+		t.Fatalf("revert of a reverted match = %v, want success", err)
+	}
+
+	for _, tt := range []struct {
+		id     uint
+		rating int
+	}{{winner.ID, 1500}, {loser.ID, 1500}} {
+		var user modles.User
+		db.Db.First(&user, tt.id)
+		if user.Rating != tt.rating || user.GamesPlayed != 0 {
+			t.Errorf("user %d = rating %d after %d games, want %d after 0", tt.id, user.Rating, user.GamesPlayed, tt.rating)
+		}
+	}
+}
@@ -5,30 +5,77 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/iAmImran007/Code_War/pkg/auth"
 	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
 	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/middleware"
 	"github.com/iAmImran007/Code_War/pkg/modles"
-	"gorm.io/gorm"
+	"github.com/iAmImran007/Code_War/pkg/rating"
+	"github.com/iAmImran007/Code_War/pkg/similarity"
 )
 
 type Room struct {
 	upgrader        websocket.Upgrader
-	waitingPlayers  []*Player
-	pairedUsers     map[*Player]*Player
-	currentProblems map[*Player]modles.ProblemPropaty
+	matchmaker      *Matchmaker
+	// players are the players in a match by user, a reconnecting user takes their seat back
+	players         map[uint]*Player
+	currentProblems map[uint]modles.ProblemPropaty
 	mu              sync.Mutex
 	db              *database.Databse
+	queue           *cppruner.JudgeQueue
+	runLimit        *middleware.RateLimiter
+	judge           cppruner.JudgeBackend
+	// similarityThreshold is the score from which an accepted submission is flagged as copied
+	similarityThreshold float64
+	durations           MatchDurations
+	// reconnectGrace is how long a player who dropped out of a match has to come back
+	reconnectGrace time.Duration
 }
 
+// Game modes a player can ask for with /ws?mode=
+const (
+	// ModeClassic is won by the first player passing every test case
+	ModeClassic = "classic"
+	// ModePoints shows both players' best scores as they go, 100 points wins
+	ModePoints = "points"
+)
+
 type Player struct {
 	conn    *websocket.Conn
 	partner *Player
 	send    chan []byte
 	solved  bool
 	UserID uint `json:"user_id"`
+
+	mode      string
+	bestScore float64
+	// matchID is the Match row of the current battle, 0 when it couldn't be stored
+	matchID uint
+	// rating is the player's rating when they joined, what the matchmaker pairs by
+	rating int
+	// closed is set once the player was cleaned up and send is closed
+	closed bool
+	// bestPassed is the most test cases one of the player's submissions passed in a row from the
+	// first, what decides a timeout. Tests after a failure may be skipped, so they don't count.
+	bestPassed int
+	// timer is the clock of the current match, shared with the partner
+	timer *matchTimer
+
+	// connMu guards conn and disconnected, SendMsg reads them without the room lock
+	connMu sync.Mutex
+	// disconnected is set while the player has reconnectGrace to come back to the match
+	disconnected bool
+	// reconnect forfeits the match when the player doesn't come back in time
+	reconnect *time.Timer
+}
+
+// ScoreUpdate is sent to both players of a points match after every judged submission
+type ScoreUpdate struct {
+	Score         float64 `json:"score"`
+	OpponentScore float64 `json:"opponent_score"`
 }
 
 type Message struct {
@@ -40,11 +87,29 @@ type Message struct {
 	Result  interface{} `json:"result,omitempty"`
 	Text    string      `json:"text,omitempty"`
 	From    string      `json:"from,omitempty"`
+	Mode    string      `json:"mode,omitempty"`
+
+	SubmissionID string `json:"submission_id,omitempty"`
+	// Diagnostics are the compiler's errors when a submission or run doesn't build
+	Diagnostics []cppruner.Diagnostic `json:"diagnostics,omitempty"`
+	// RatingDelta is the player's rating change, sent with game_end
+	RatingDelta int `json:"rating_delta,omitempty"`
+	// RemainingSeconds is the match time left, sent with problem and timer
+	RemainingSeconds int `json:"remaining_seconds,omitempty"`
 }
 
 type SubmissionMessage struct {
-	Type string `json:"type"`
-	Code string `json:"code"`
+	Type     string `json:"type"`
+	Code     string `json:"code"`
+	Language string `json:"language"`
+}
+
+// RunMessage asks for a custom run, Input is the stdin and the examples are used without it
+type RunMessage struct {
+	Type     string  `json:"type"`
+	Code     string  `json:"code"`
+	Language string  `json:"language"`
+	Input    *string `json:"input,omitempty"`
 }
 
 type ChatMsg struct {
@@ -52,16 +117,26 @@ type ChatMsg struct {
 	Text string `json:"text"`
 }
 
-func NewRoom(db *database.Databse) *Room {
-	return &Room{
+func NewRoom(db *database.Databse, queue *cppruner.JudgeQueue, runLimit *middleware.RateLimiter, judge cppruner.JudgeBackend) *Room {
+	rm := &Room{
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
-		waitingPlayers:  []*Player{},
-		pairedUsers:     make(map[*Player]*Player),
-		currentProblems: make(map[*Player]modles.ProblemPropaty),
+		players:         make(map[uint]*Player),
+		currentProblems: make(map[uint]modles.ProblemPropaty),
 		db:              db,
+		queue:           queue,
+		runLimit:        runLimit,
+		judge:           judge,
+
+		similarityThreshold: similarity.ThresholdFromEnv(),
+		durations:           MatchDurationsFromEnv(),
+		reconnectGrace:      ReconnectGraceFromEnv(),
 	}
+
+	rm.matchmaker = NewMatchmaker(realClock{}, DefaultMatchmakerConfig(), rm.startMatch, rm.sendQueueStatus)
+	rm.matchmaker.Start()
+	return rm
 }
 
 func (rm *Room) HandleWs(w http.ResponseWriter, r *http.Request) {
@@ -78,12 +153,30 @@ func (rm *Room) HandleWs(w http.ResponseWriter, r *http.Request) {
 	    return
     }
 
+	// A player who dropped out of a match takes their seat back instead of queueing again
+	if rm.rejoin(userID, conn) {
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode != ModePoints {
+		mode = ModeClassic
+	}
+
 	player := &Player{
 		conn:    conn,
 		partner: nil,
 		send:    make(chan []byte, 10),
 		solved:  false,
 		UserID: userID,
+		mode:    mode,
+		rating:  rating.InitialRating,
+	}
+
+	// The matchmaker pairs by rating
+	var user modles.User
+	if err := rm.db.Db.Select("id", "rating").Where("id = ?", userID).First(&user).Error; err == nil {
+		player.rating = user.Rating
 	}
 
 	go rm.SendMsg(player)
@@ -92,88 +185,180 @@ func (rm *Room) HandleWs(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("New player connected")
 }
 
+// SendMsg writes the player's messages to whatever connection they have until they're cleaned up.
+// Messages sent while the player is reconnecting are dropped, resuming sends what matters again.
 func (rm *Room) SendMsg(player *Player) {
-	defer func() {
-		player.conn.Close()
-		rm.handlePlayerDisconnect(player)
-	}()
-
 	for msg := range player.send {
-		if err := player.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		player.connMu.Lock()
+		conn, disconnected := player.conn, player.disconnected
+		player.connMu.Unlock()
+		if disconnected {
+			continue
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
 			fmt.Println("Write error:", err)
-			break
+			conn.Close()
+			// In the background so the channel keeps draining while the room is busy
+			go rm.handlePlayerDisconnect(player, conn)
 		}
 	}
+
+	player.connMu.Lock()
+	player.conn.Close()
+	player.connMu.Unlock()
 }
 
+// AddNewPlayer puts a player in the matchmaker's queue, pairing happens on its next tick
 func (rm *Room) AddNewPlayer(player *Player) {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
 
-	if len(rm.waitingPlayers) > 0 {
-		partner := rm.waitingPlayers[0]
-		rm.waitingPlayers = rm.waitingPlayers[1:]
+	rm.matchmaker.Add(player)
 
-		player.partner = partner
-		partner.partner = player
-		rm.pairedUsers[player] = partner
-		rm.pairedUsers[partner] = player
+	waitingMsg := Message{
+		Type:   "status",
+		Status: "waiting",
+		Msg:    "Waiting for an opponent...",
+	}
 
-		problem, err := database.GetRandomProblem(rm.db)
-		if err != nil {
-			fmt.Println("Error loading problem:", err)
-			rm.handleErrorAndCleanup(player, partner, "Failed to load problem")
-			return
-		}
+	waitingJSON, _ := json.Marshal(waitingMsg)
+	player.send <- waitingJSON
+
+	fmt.Println("Player added to waiting list")
+}
 
-		rm.currentProblems[player] = *problem
-		rm.currentProblems[partner] = *problem
+// startMatch pairs two players picked by the matchmaker and hands them their problem
+func (rm *Room) startMatch(partner *Player, player *Player) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 
-		problemMsg := Message{
-			Type:    "problem",
-			Status:  "ready",
-			Msg:     "Match found! Here's your problem:",
-			Problem: problem,
+	// Either may have left between the matchmaker's pick and now, the other one waits on
+	if partner.closed || player.closed {
+		for _, p := range []*Player{partner, player} {
+			if !p.closed {
+				rm.matchmaker.Add(p)
+			}
 		}
+		return
+	}
 
-		problemJSON, err := json.Marshal(problemMsg)
-		if err != nil {
-			fmt.Println("Error marshalling problem:", err)
-			rm.handleErrorAndCleanup(player, partner, "Internal server error")
-			return
-		}
+	player.partner = partner
+	partner.partner = player
+	rm.players[player.UserID] = player
+	rm.players[partner.UserID] = partner
 
-		player.send <- problemJSON
-		partner.send <- problemJSON
+	problem, err := database.GetRandomProblem(rm.db)
+	if err != nil {
+		fmt.Println("Error loading problem:", err)
+		rm.handleErrorAndCleanup(player, partner, "Failed to load problem")
+		return
+	}
 
-		fmt.Println("Two users paired with problem ID:", problem.ID)
+	rm.currentProblems[player.UserID] = *problem
+	rm.currentProblems[partner.UserID] = *problem
 
-		go rm.ListenForSolutions(player)
-		go rm.ListenForSolutions(partner)
-	} else {
-		rm.waitingPlayers = append(rm.waitingPlayers, player)
+	// The match is recorded so its submissions can be checked for copying and its result reverted
+	match := modles.Match{ProblemID: problem.ID, Mode: player.mode, Player1ID: partner.UserID, Player2ID: player.UserID, StartedAt: time.Now()}
+	if err := database.CreateMatch(rm.db, &match); err != nil {
+		fmt.Printf("Error recording match: %v\n", err)
+	}
+	player.matchID = match.ID
+	partner.matchID = match.ID
+
+	// Both share one clock, it decides the match if nobody solves it in time
+	timer := newMatchTimer(rm.durations.For(problem.Difficulty))
+	player.timer = timer
+	partner.timer = timer
+
+	problemMsg := Message{
+		Type:    "problem",
+		Status:  "ready",
+		Msg:     "Match found! Here's your problem:",
+		Problem: publicProblem(problem),
+		Mode:    player.mode,
+
+		RemainingSeconds: int(timer.remaining() / time.Second),
+	}
 
-		waitingMsg := Message{
-			Type:   "status",
-			Status: "waiting",
-			Msg:    "Waiting for an opponent...",
-		}
+	problemJSON, err := json.Marshal(problemMsg)
+	if err != nil {
+		fmt.Println("Error marshalling problem:", err)
+		rm.handleErrorAndCleanup(player, partner, "Internal server error")
+		return
+	}
+
+	player.send <- problemJSON
+	partner.send <- problemJSON
+
+	fmt.Println("Two users paired with problem ID:", problem.ID)
+
+	go rm.ListenForSolutions(player, player.conn)
+	go rm.ListenForSolutions(partner, partner.conn)
+	go rm.runTimer(partner, player, timer)
+}
+
+// publicProblem is what players see of a problem: the statement only, hidden tests
+// and the judge's own sources stay on the server
+func publicProblem(problem *modles.ProblemPropaty) *modles.ProblemPropaty {
+	public := *problem
+	public.TestCases = nil
+	public.CheckerSource = ""
+	public.InteractorSource = ""
+	public.ReferenceSource = ""
+	public.GeneratorSource = ""
+	public.ValidatorSource = ""
+	return &public
+}
+
+// inMatch reports whether player still holds a seat in a match. Caller holds rm.mu.
+func (rm *Room) inMatch(player *Player) bool {
+	return !player.closed && rm.players[player.UserID] == player
+}
+
+// leaveMatch frees player's seat, unless a newer session of the same user took it. Caller holds rm.mu.
+func (rm *Room) leaveMatch(player *Player) {
+	if rm.players[player.UserID] == player {
+		delete(rm.players, player.UserID)
+		delete(rm.currentProblems, player.UserID)
+	}
+}
+
+// sendQueueStatus tells a waiting player how the search is going
+func (rm *Room) sendQueueStatus(player *Player, status QueueStatus) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 
-		waitingJSON, _ := json.Marshal(waitingMsg)
-		player.send <- waitingJSON
+	if player.closed || player.partner != nil {
+		return
+	}
 
-		fmt.Println("Player added to waiting list")
+	msg := fmt.Sprintf("Searching for an opponent rated within ±%d (waited %ds)", status.Band, status.WaitedSeconds)
+	if status.EstimateSeconds > 0 {
+		msg += fmt.Sprintf(", about %ds left", status.EstimateSeconds)
+	}
+	statusMsg := Message{
+		Type:   "status",
+		Status: "waiting",
+		Msg:    msg,
+		Result: status,
+	}
+	statusJSON, _ := json.Marshal(statusMsg)
+	select {
+	case player.send <- statusJSON:
+	default:
 	}
 }
 
-func (rm *Room) ListenForSolutions(player *Player) {
+// ListenForSolutions reads the player's messages from conn until it fails
+func (rm *Room) ListenForSolutions(player *Player, conn *websocket.Conn) {
 	defer func() {
-		player.conn.Close()
-		rm.handlePlayerDisconnect(player)
+		conn.Close()
+		rm.handlePlayerDisconnect(player, conn)
 	}()
 
 	for {
-		_, message, err := player.conn.ReadMessage()
+		_, message, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				fmt.Printf("unexpected close error from player %v\n", err)
@@ -202,7 +387,18 @@ func (rm *Room) ListenForSolutions(player *Player) {
 				fmt.Printf("Submission unmarshal error from player %v\n", err)
 				continue
 			}
-			rm.handleSubmission(player, submission.Code)
+			rm.handleSubmission(player, submission.Language, submission.Code)
+
+		case "run":
+			var run RunMessage
+			if err := json.Unmarshal(message, &run); err != nil {
+				fmt.Printf("Run unmarshal error from player %v\n", err)
+				continue
+			}
+			go rm.handleRun(player, run)
+
+		case "surrender":
+			rm.handleSurrender(player)
 
 		case "chat":
 			var chatMsg ChatMsg
@@ -218,53 +414,142 @@ func (rm *Room) ListenForSolutions(player *Player) {
 	}
 }
 
-func (rm *Room) handleSubmission(player *Player, code string) {
+func (rm *Room) handleSubmission(player *Player, language string, code string) {
 	rm.mu.Lock()
-	defer rm.mu.Unlock()
-
-	if player.solved {
-		return
-	}
-
-	problem, exists := rm.currentProblems[player]
-	if !exists {
+	if player.solved || !rm.inMatch(player) {
+		rm.mu.Unlock()
 		return
 	}
+	problem := rm.currentProblems[player.UserID]
+	rm.mu.Unlock()
 
-	fmt.Printf("Judging submission for player with problem ID: %d\n", problem.ID)
+	fmt.Printf("Queueing submission for player with problem ID: %d\n", problem.ID)
 
 	// Convert TestCaesPropaty to TestCase for judge function
-	var testCases []cppruner.TestCase
-	for _, tc := range problem.TestCases {
-		testCases = append(testCases, cppruner.TestCase{
-			Input:          tc.Input,
-			ExpectedOutput: tc.ExpectedOutput,
-		})
+	testCases := cppruner.HiddenTestCases(&problem)
+
+	// A classic battle only needs the first failure, points mode scores every test
+	stop := cppruner.StopOnFirstFailure
+	if player.mode == ModePoints {
+		stop = cppruner.RunAll
+	}
+
+	sub := &modles.MatchSubmission{
+		MatchID:   player.matchID,
+		ProblemID: problem.ID,
+		UserID:    player.UserID,
+		Language:  language,
+		Code:      code,
+	}
+	if player.matchID != 0 {
+		if err := database.SaveMatchSubmission(rm.db, sub); err != nil {
+			fmt.Printf("Error recording submission: %v\n", err)
+		}
 	}
 
-	result, err := cppruner.JudgeCode(problem.ID, code, testCases, rm.db)
+	// Judging happens on the queue workers so the room lock is never held while code runs
+	submissionID, err := rm.queue.Submit(cppruner.JudgeJob{
+		ProblemID:  problem.ID,
+		UserID:     player.UserID,
+		Language:   language,
+		Code:       code,
+		TestCases:  testCases,
+		StopPolicy: stop,
+	}, func(state cppruner.SubmissionState) {
+		if state.Finished() {
+			rm.recordSubmissionResult(sub, &problem, state)
+		}
+		rm.handleSubmissionUpdate(player, state)
+	})
 	if err != nil {
 		fmt.Printf("Judge error: %v\n", err)
 		errorMsg := Message{
 			Type:   "error",
 			Status: "error",
-			Msg:    "Compilation or runtime error: " + err.Error(),
+			Msg:    "Failed to submit: " + err.Error(),
+		}
+		errorJSON, _ := json.Marshal(errorMsg)
+		player.send <- errorJSON
+		return
+	}
+
+	fmt.Printf("Submission %s queued\n", submissionID)
+}
+
+// handleSubmissionUpdate pushes judge progress to the player and settles the match once judged
+func (rm *Room) handleSubmissionUpdate(player *Player, state cppruner.SubmissionState) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.submissionUpdate(player, state)
+}
+
+// submissionUpdate is handleSubmissionUpdate for a caller holding rm.mu
+func (rm *Room) submissionUpdate(player *Player, state cppruner.SubmissionState) {
+	// The match may have ended or the player left while the submission was queued
+	if !rm.inMatch(player) || player.solved {
+		return
+	}
+
+	if !state.Finished() {
+		msg := "Submission queued"
+		switch state.Status {
+		case cppruner.StatusCompiling:
+			msg = "Compiling..."
+		case cppruner.StatusRunning:
+			msg = fmt.Sprintf("Running test %d/%d", state.CurrentTest, state.TotalTests)
+		}
+		statusMsg := Message{
+			Type:         "submission_status",
+			Status:       string(state.Status),
+			Msg:          msg,
+			SubmissionID: state.ID,
+		}
+		statusJSON, _ := json.Marshal(statusMsg)
+		select {
+		case player.send <- statusJSON:
+		default:
+		}
+		return
+	}
+
+	if state.Result == nil || state.Result.Verdict == cppruner.VerdictCompileError {
+		fmt.Printf("Judge error: %s\n", state.Error)
+		errorMsg := Message{
+			Type:         "error",
+			Status:       "error",
+			Msg:          "Compilation or runtime error: " + state.Error,
+			SubmissionID: state.ID,
+		}
+		if state.Result != nil {
+			errorMsg.Diagnostics = state.Result.CompileErrors
 		}
 		errorJSON, _ := json.Marshal(errorMsg)
 		player.send <- errorJSON
 		return
 	}
 
+	result := state.Result
+
 	// Send result to the player who submitted
 	resultMsg := Message{
-		Type:   "result",
-		Status: "judged",
-		Msg:    fmt.Sprintf("Passed %d/%d test cases", result.Passed, result.Total),
-		Result: result,
+		Type:         "result",
+		Status:       "judged",
+		Msg:          fmt.Sprintf("%s: passed %d/%d test cases", result.Verdict, result.Passed, result.Total),
+		Result:       result.Redacted(),
+		SubmissionID: state.ID,
 	}
 	resultJSON, _ := json.Marshal(resultMsg)
 	player.send <- resultJSON
 
+	if passed := result.PassedPrefix(); passed > player.bestPassed {
+		player.bestPassed = passed
+	}
+
+	if player.mode == ModePoints {
+		rm.handleScore(player, result.Score)
+		return
+	}
+
 	// Check if player won (solved all test cases)
 	if result.Passed == result.Total {
 		player.solved = true
@@ -272,61 +557,275 @@ func (rm *Room) handleSubmission(player *Player, code string) {
 	}
 }
 
+// recordSubmissionResult stores the verdict of a match submission and checks accepted code
+// against other players' submissions and the reference solution
+func (rm *Room) recordSubmissionResult(sub *modles.MatchSubmission, problem *modles.ProblemPropaty, state cppruner.SubmissionState) {
+	if sub.ID == 0 || state.Result == nil {
+		return
+	}
+
+	result := state.Result
+	if err := database.UpdateMatchSubmissionResult(rm.db, sub.ID, string(result.Verdict), result.Score); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if result.Verdict != cppruner.VerdictAccepted {
+		return
+	}
+
+	go func() {
+		flags, err := similarity.CheckSubmission(rm.db, sub, problem, rm.similarityThreshold)
+		if err != nil {
+			fmt.Printf("Similarity check failed: %v\n", err)
+			return
+		}
+		for _, f := range flags {
+			fmt.Printf("Submission %d of user %d flagged for review: %.0f%% similar to submission %d of user %d\n",
+				f.SubmissionID, f.UserID, f.Score*100, f.OtherSubmissionID, f.OtherUserID)
+		}
+	}()
+}
+
+// handleScore keeps a points match player's best score, shows it to both sides
+// and ends the match once someone reaches full marks. Caller holds rm.mu.
+func (rm *Room) handleScore(player *Player, score float64) {
+	if score > player.bestScore {
+		player.bestScore = score
+	}
+
+	partner := player.partner
+	if partner == nil {
+		return
+	}
+
+	for _, p := range []*Player{player, partner} {
+		scoreMsg := Message{
+			Type:   "score_update",
+			Status: "scored",
+			Msg:    fmt.Sprintf("You %g - %g opponent", p.bestScore, p.partner.bestScore),
+			Result: ScoreUpdate{Score: p.bestScore, OpponentScore: p.partner.bestScore},
+		}
+		scoreJSON, _ := json.Marshal(scoreMsg)
+		select {
+		case p.send <- scoreJSON:
+		default:
+		}
+	}
+
+	if player.bestScore >= cppruner.MaxScore {
+		player.solved = true
+		rm.handleGameWin(player)
+	}
+}
+
+// handleRun executes code on custom input for the player only, the match is not affected
+func (rm *Room) handleRun(player *Player, run RunMessage) {
+	rm.mu.Lock()
+	if !rm.inMatch(player) {
+		rm.mu.Unlock()
+		return
+	}
+	problem := rm.currentProblems[player.UserID]
+	rm.mu.Unlock()
+
+	var result cppruner.JudgeResult
+	var err error
+	if !rm.runLimit.Allow(player.UserID) {
+		err = fmt.Errorf("too many runs, please wait a moment")
+	} else {
+		var inputs []string
+		if run.Input != nil {
+			inputs = []string{*run.Input}
+		}
+		result, err = rm.judge.Judge(cppruner.JudgeJob{
+			ProblemID: problem.ID,
+			UserID:    player.UserID,
+			Language:  run.Language,
+			Code:      run.Code,
+			Run:       true,
+			Inputs:    inputs,
+		}, nil)
+	}
+
+	msg := Message{
+		Type:   "run_result",
+		Status: string(result.Verdict),
+		Result: result,
+	}
+	if compileErr, ok := err.(*cppruner.CompileError); ok {
+		msg.Msg = "Compilation failed: " + compileErr.Details()
+		msg.Diagnostics = compileErr.Diagnostics
+		msg.Result = nil
+	} else if err != nil {
+		msg = Message{
+			Type:   "error",
+			Status: "error",
+			Msg:    "Failed to run: " + err.Error(),
+		}
+	}
+	msgJSON, _ := json.Marshal(msg)
+
+	// The match may have ended while the code ran and the send channel be gone
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if !rm.inMatch(player) {
+		return
+	}
+	select {
+	case player.send <- msgJSON:
+	default:
+	}
+}
+
 func (rm *Room) handleGameWin(winner *Player) {
 	partner := winner.partner
 	if partner == nil {
 		return
 	}
+	// Both are done, a submission of the partner judged before the cleanup can't win it again
+	winner.solved = true
+	partner.solved = true
+
+	// Rate the match first so both players see their rating change
+	match := rm.updatePlayerRating(winner, partner, modles.MatchWin, modles.EndSolved)
 
 	// Send win message to winner
 	winMsg := Message{
-		Type:   "game_end",
-		Status: "win",
-		Msg:    "Congratulations! You won the match!",
+		Type:        "game_end",
+		Status:      "win",
+		Msg:         "Congratulations! You won the match!",
+		RatingDelta: ratingDelta(match, winner.UserID),
 	}
 	winJSON, _ := json.Marshal(winMsg)
 	winner.send <- winJSON
 
 	// Send lose message to opponent
 	loseMsg := Message{
-		Type:   "game_end",
-		Status: "lose",
-		Msg:    "You lost! Your opponent solved the problem first.",
+		Type:        "game_end",
+		Status:      "lose",
+		Msg:         "You lost! Your opponent solved the problem first.",
+		RatingDelta: ratingDelta(match, partner.UserID),
 	}
 	loseJSON, _ := json.Marshal(loseMsg)
 	partner.send <- loseJSON
 
-	//increiess player rating by 5 after winning
-	rm.updatePlayerRating(winner, partner)
-
 	fmt.Println("Game finished - winner determined")
 
 	// Clean up after a short delay to allow messages to be sent coz
 	go func() {
+		rm.mu.Lock()
+		defer rm.mu.Unlock()
 		rm.CleanupPlayers(winner)
 		rm.CleanupPlayers(partner)
 	}()
 }
 
-func (rm *Room) handlePlayerDisconnect(player *Player) {
+// handleSurrender ends the match in the opponent's favour when a player gives up
+func (rm *Room) handleSurrender(player *Player) {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
 
+	partner := player.partner
+	if partner == nil || player.solved || partner.solved {
+		return
+	}
+	// Both are done, nothing they send or a disconnect can change the result now
+	player.solved = true
+	partner.solved = true
+
+	match := rm.updatePlayerRating(partner, player, modles.MatchForfeit, modles.EndSurrender)
+
+	loseMsg := Message{
+		Type:        "game_end",
+		Status:      "lose",
+		Msg:         "You surrendered.",
+		RatingDelta: ratingDelta(match, player.UserID),
+	}
+	loseJSON, _ := json.Marshal(loseMsg)
+	player.send <- loseJSON
+
+	winMsg := Message{
+		Type:        "game_end",
+		Status:      "win",
+		Msg:         "You won! Your opponent surrendered.",
+		RatingDelta: ratingDelta(match, partner.UserID),
+	}
+	winJSON, _ := json.Marshal(winMsg)
+	partner.send <- winJSON
+
+	fmt.Println("Player surrendered - opponent wins")
+
+	go func() {
+		rm.mu.Lock()
+		defer rm.mu.Unlock()
+		rm.CleanupPlayers(player)
+		rm.CleanupPlayers(partner)
+	}()
+}
+
+// handlePlayerDisconnect deals with conn of player failing. A player in an open match gets
+// reconnectGrace to come back before forfeiting, anyone else is cleaned up right away.
+func (rm *Room) handlePlayerDisconnect(player *Player, conn *websocket.Conn) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	// Only the player's current connection counts, an old one failing after a resume doesn't
+	player.connMu.Lock()
+	current := player.conn == conn && !player.disconnected
+	player.connMu.Unlock()
+	if !current || player.closed {
+		return
+	}
+
+	if player.partner != nil && !player.solved && rm.reconnectGrace > 0 {
+		player.connMu.Lock()
+		player.disconnected = true
+		player.connMu.Unlock()
+		player.reconnect = time.AfterFunc(rm.reconnectGrace, func() { rm.handleReconnectExpired(player) })
+
+		rm.notifyPartner(player, Message{
+			Type:             "opponent_status",
+			Status:           "reconnecting",
+			Msg:              "Opponent reconnecting...",
+			RemainingSeconds: int(rm.reconnectGrace / time.Second),
+		})
+		fmt.Printf("Player %d disconnected, waiting %s for them to reconnect\n", player.UserID, rm.reconnectGrace)
+		return
+	}
+
+	rm.forfeit(player)
+}
+
+// handleReconnectExpired forfeits the match of a player who didn't come back in time
+func (rm *Room) handleReconnectExpired(player *Player) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if !player.disconnected || player.closed {
+		return
+	}
+	rm.forfeit(player)
+}
+
+// forfeit gives an open match to the partner of a player who left and cleans the player up.
+// Caller holds rm.mu.
+func (rm *Room) forfeit(player *Player) {
 	if player.partner != nil && !player.solved {
 		// If player has a partner and game is ongoing, partner wins
 		partner := player.partner
 
+		// Leaving an open match forfeits it
+		match := rm.updatePlayerRating(partner, player, modles.MatchForfeit, modles.EndDisconnect)
+
 		winMsg := Message{
-			Type:   "game_end",
-			Status: "win",
-			Msg:    "You won! Your opponent disconnected.",
+			Type:        "game_end",
+			Status:      "win",
+			Msg:         "You won! Your opponent disconnected.",
+			RatingDelta: ratingDelta(match, partner.UserID),
 		}
 		winJSON, _ := json.Marshal(winMsg)
 		partner.send <- winJSON
 
-		//update the player rating before desconnect
-		rm.updatePlayerRating(partner, player)
-
 		fmt.Println("Player disconnected - opponent wins by default")
 	}
 
@@ -354,32 +853,27 @@ func (rm *Room) handleErrorAndCleanup(player, partner *Player, errorMsg string)
 }
 
 func (rm *Room) CleanupPlayers(player *Player) {
+	// The match is over, its clock and reconnect window go with it
+	player.timer.Stop()
+	if player.reconnect != nil {
+		player.reconnect.Stop()
+	}
+
 	if player.partner != nil {
 		partner := player.partner
 
-		delete(rm.pairedUsers, player)
-		delete(rm.pairedUsers, partner)
-
-		delete(rm.currentProblems, player)
-		delete(rm.currentProblems, partner)
+		rm.leaveMatch(player)
+		rm.leaveMatch(partner)
 
 		partner.partner = nil
 		player.partner = nil
 	} else {
-		for i, p := range rm.waitingPlayers {
-			if p == player {
-				rm.waitingPlayers = append(rm.waitingPlayers[:i], rm.waitingPlayers[i+1:]...)
-				break
-			}
-		}
-
-		delete(rm.pairedUsers, player)
-		delete(rm.currentProblems, player)
+		rm.matchmaker.Remove(player)
+		rm.leaveMatch(player)
 	}
 
-	select {
-	case <-player.send:
-	default:
+	if !player.closed {
+		player.closed = true
 		close(player.send)
 	}
 
@@ -418,16 +912,41 @@ func (rm *Room) handleChatMsg(player *Player, text string) {
 
 }
 
-func (rm *Room) updatePlayerRating(winner *Player, loser *Player) {
-	// Update winner's rating (+5)
-	if err := rm.db.Db.Model(&modles.User{}).Where("id = ?", winner.UserID).
-		UpdateColumn("rating", gorm.Expr("rating + ?", 5)).Error; err != nil {
-		fmt.Printf("Error updating winner rating: %v\n", err)
+// updatePlayerRating decides the match and rates both players in one go, result is
+// modles.MatchWin or MatchForfeit (won by winner) or MatchDraw (either order), endReason
+// what ended it. Returns the decided match with both rating changes, nil when it couldn't be rated.
+func (rm *Room) updatePlayerRating(winner *Player, loser *Player, result, endReason string) *modles.Match {
+	if winner.matchID == 0 {
+		fmt.Println("Match was not recorded, ratings stay as they are")
+		return nil
 	}
-	
-	fmt.Printf("Updated winner rating (+5)\n")
+
+	var winnerID uint
+	if result != modles.MatchDraw {
+		winnerID = winner.UserID
+	}
+	match, err := database.RecordMatchResult(rm.db, winner.matchID, winnerID, result, endReason)
+	if err != nil {
+		fmt.Printf("Error updating ratings: %v\n", err)
+		return nil
+	}
+
+	fmt.Printf("Match %d rated (%s): user %d %+d, user %d %+d\n", match.ID, result,
+		match.Player1ID, match.Player1RatingDelta, match.Player2ID, match.Player2RatingDelta)
+	return match
 }
 
+// ratingDelta is what a decided match did to userID's rating, 0 without a match
+func ratingDelta(match *modles.Match, userID uint) int {
+	switch {
+	case match == nil:
+		return 0
+	case match.Player1ID == userID:
+		return match.Player1RatingDelta
+	default:
+		return match.Player2RatingDelta
+	}
+}
 
 func (rm *Room) getUserIDFromRequest(r *http.Request) uint {
 	// Get access token from cookie
@@ -0,0 +1,54 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+func TestGameWinRace(t *testing.T) {
+	accepted := cppruner.SubmissionState{
+		Status: cppruner.StatusDone,
+		Result: &cppruner.JudgeResult{Verdict: cppruner.VerdictAccepted, Passed: 3, Total: 3, Score: cppruner.MaxScore},
+	}
+
+	for _, mode := range []string{ModeClassic, ModePoints} {
+		t.Run(mode, func(t *testing.T) {
+			rm := &Room{
+				players:         make(map[uint]*Player),
+				currentProblems: make(map[uint]modles.ProblemPropaty),
+				matchmaker:      NewMatchmaker(newFakeClock(), DefaultMatchmakerConfig(), func(a, b *Player) {}, func(p *Player, status QueueStatus) {}),
+			}
+			a := &Player{UserID: 1, mode: mode, send: make(chan []byte, 10)}
+			b := &Player{UserID: 2, mode: mode, send: make(chan []byte, 10)}
+			a.partner, b.partner = b, a
+			rm.players[a.UserID], rm.players[b.UserID] = a, b
+
+			// Both accepted submissions are handled before the cleanup after the first win gets the lock
+			rm.mu.Lock()
+			rm.submissionUpdate(a, accepted)
+			rm.submissionUpdate(b, accepted)
+			rm.mu.Unlock()
+
+			// The cleanup closes both channels, they then hold everything the players were sent
+			for _, tt := range []struct {
+				player *Player
+				want   string
+			}{{a, "win"}, {b, "lose"}} {
+				var ends []string
+				for raw := range tt.player.send {
+					var msg Message
+					json.Unmarshal(raw, &msg)
+					if msg.Type == "game_end" {
+						ends = append(ends, msg.Status)
+					}
+				}
+				if len(ends) != 1 || ends[0] != tt.want {
+					t.Errorf("player %d got game_end %v, want [%s]", tt.player.UserID, ends, tt.want)
+				}
+			}
+		})
+	}
+}
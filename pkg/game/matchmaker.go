@@ -0,0 +1,274 @@
+package game
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the matchmaker's time source, a fake one lets tests move time by hand
+type Clock interface {
+	Now() time.Time
+	// Tick delivers the time every d, for as long as the process runs
+	Tick(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                        { return time.Now() }
+func (realClock) Tick(d time.Duration) <-chan time.Time { return time.Tick(d) }
+
+// MatchmakerConfig controls how far apart in rating players may be paired
+type MatchmakerConfig struct {
+	// Interval is how often waiting players are matched
+	Interval time.Duration
+	// A player accepts opponents within BaseBand rating points, growing by BandGrowth
+	// every BandStep waited up to MaxBand
+	BaseBand   int
+	BandGrowth int
+	BandStep   time.Duration
+	MaxBand    int
+	// RematchAfter is how long both players must wait before they're paired again
+	// right after playing each other
+	RematchAfter time.Duration
+	// StatusInterval is how often waiting players get a status update
+	StatusInterval time.Duration
+}
+
+// DefaultMatchmakerConfig starts at ±100 and widens by 50 every 10 seconds up to ±1000
+func DefaultMatchmakerConfig() MatchmakerConfig {
+	return MatchmakerConfig{
+		Interval:       time.Second,
+		BaseBand:       100,
+		BandGrowth:     50,
+		BandStep:       10 * time.Second,
+		MaxBand:        1000,
+		RematchAfter:   time.Minute,
+		StatusInterval: 5 * time.Second,
+	}
+}
+
+// QueueStatus is sent to a waiting player every StatusInterval
+type QueueStatus struct {
+	WaitedSeconds int `json:"waited_seconds"`
+	// EstimateSeconds is the expected time left, 0 when there's no estimate
+	EstimateSeconds int `json:"estimate_seconds"`
+	Band            int `json:"band"`
+	Waiting         int `json:"waiting"`
+}
+
+// recentWaits is how many past waits per mode the estimate averages
+const recentWaits = 20
+
+// Matchmaker pairs waiting players of the same mode by rating. It only decides who plays whom,
+// pair and status are called outside its lock to start matches and talk to players.
+type Matchmaker struct {
+	clock  Clock
+	config MatchmakerConfig
+	pair   func(a, b *Player)
+	status func(p *Player, status QueueStatus)
+
+	mu      sync.Mutex
+	tickets []*ticket
+	// lastOpponent is who each user played last, to avoid immediate rematches
+	lastOpponent map[uint]uint
+	waits        map[string][]time.Duration
+}
+
+type ticket struct {
+	player     *Player
+	joinedAt   time.Time
+	lastStatus time.Time
+}
+
+func NewMatchmaker(clock Clock, config MatchmakerConfig, pair func(a, b *Player), status func(p *Player, status QueueStatus)) *Matchmaker {
+	return &Matchmaker{
+		clock:        clock,
+		config:       config,
+		pair:         pair,
+		status:       status,
+		lastOpponent: make(map[uint]uint),
+		waits:        make(map[string][]time.Duration),
+	}
+}
+
+// Start runs Tick every Interval in the background
+func (mm *Matchmaker) Start() {
+	go func() {
+		for range mm.clock.Tick(mm.config.Interval) {
+			mm.Tick()
+		}
+	}()
+}
+
+// Add puts a player in the queue, adding one twice is a no-op
+func (mm *Matchmaker) Add(player *Player) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	for _, t := range mm.tickets {
+		if t.player == player {
+			return
+		}
+	}
+	now := mm.clock.Now()
+	mm.tickets = append(mm.tickets, &ticket{player: player, joinedAt: now, lastStatus: now})
+}
+
+// Remove takes a player out of the queue and reports whether it was waiting
+func (mm *Matchmaker) Remove(player *Player) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	for i, t := range mm.tickets {
+		if t.player == player {
+			mm.tickets = append(mm.tickets[:i], mm.tickets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Waiting is the number of players in the queue
+func (mm *Matchmaker) Waiting() int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return len(mm.tickets)
+}
+
+// Band is how far from its own rating a player who waited for waited accepts opponents
+func (mm *Matchmaker) Band(waited time.Duration) int {
+	band := mm.config.BaseBand
+	if mm.config.BandStep > 0 {
+		band += int(waited/mm.config.BandStep) * mm.config.BandGrowth
+	}
+	if band > mm.config.MaxBand {
+		band = mm.config.MaxBand
+	}
+	return band
+}
+
+// Tick pairs whoever can be paired, longest waiting first with the closest rating, and
+// sends status updates to those left waiting
+func (mm *Matchmaker) Tick() {
+	type update struct {
+		player *Player
+		status QueueStatus
+	}
+	var pairs [][2]*Player
+	var updates []update
+
+	mm.mu.Lock()
+	now := mm.clock.Now()
+	sort.SliceStable(mm.tickets, func(i, j int) bool { return mm.tickets[i].joinedAt.Before(mm.tickets[j].joinedAt) })
+
+	paired := make(map[*ticket]bool)
+	for i, a := range mm.tickets {
+		if paired[a] {
+			continue
+		}
+		var best *ticket
+		bestDiff := 0
+		for _, b := range mm.tickets[i+1:] {
+			if paired[b] || !mm.compatible(a, b, now) {
+				continue
+			}
+			if diff := abs(a.player.rating - b.player.rating); best == nil || diff < bestDiff {
+				best, bestDiff = b, diff
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		paired[a], paired[best] = true, true
+		pairs = append(pairs, [2]*Player{a.player, best.player})
+		mm.lastOpponent[a.player.UserID] = best.player.UserID
+		mm.lastOpponent[best.player.UserID] = a.player.UserID
+		mm.recordWait(a.player.mode, now.Sub(a.joinedAt))
+		mm.recordWait(a.player.mode, now.Sub(best.joinedAt))
+	}
+
+	remaining := mm.tickets[:0]
+	for _, t := range mm.tickets {
+		if paired[t] {
+			continue
+		}
+		remaining = append(remaining, t)
+	}
+	mm.tickets = remaining
+
+	for _, t := range mm.tickets {
+		if now.Sub(t.lastStatus) < mm.config.StatusInterval {
+			continue
+		}
+		t.lastStatus = now
+		waited := now.Sub(t.joinedAt)
+		updates = append(updates, update{t.player, QueueStatus{
+			WaitedSeconds:   int(waited / time.Second),
+			EstimateSeconds: int(mm.estimate(t.player.mode, waited) / time.Second),
+			Band:            mm.Band(waited),
+			Waiting:         len(mm.tickets),
+		}})
+	}
+	mm.mu.Unlock()
+
+	for _, p := range pairs {
+		mm.pair(p[0], p[1])
+	}
+	for _, u := range updates {
+		mm.status(u.player, u.status)
+	}
+}
+
+// compatible reports whether a and b may play each other now: same mode, different users,
+// ratings within the wider of their bands and no rematch unless both waited RematchAfter
+func (mm *Matchmaker) compatible(a, b *ticket, now time.Time) bool {
+	if a.player.mode != b.player.mode || a.player.UserID == b.player.UserID {
+		return false
+	}
+
+	waitedA, waitedB := now.Sub(a.joinedAt), now.Sub(b.joinedAt)
+	band := mm.Band(waitedA)
+	if bb := mm.Band(waitedB); bb > band {
+		band = bb
+	}
+	if abs(a.player.rating-b.player.rating) > band {
+		return false
+	}
+
+	rematch := mm.lastOpponent[a.player.UserID] == b.player.UserID || mm.lastOpponent[b.player.UserID] == a.player.UserID
+	return !rematch || (waitedA >= mm.config.RematchAfter && waitedB >= mm.config.RematchAfter)
+}
+
+func (mm *Matchmaker) recordWait(mode string, waited time.Duration) {
+	waits := append(mm.waits[mode], waited)
+	if len(waits) > recentWaits {
+		waits = waits[len(waits)-recentWaits:]
+	}
+	mm.waits[mode] = waits
+}
+
+// estimate is the average recent wait in mode minus what was waited already,
+// 0 without recent matches or once the average has passed
+func (mm *Matchmaker) estimate(mode string, waited time.Duration) time.Duration {
+	waits := mm.waits[mode]
+	if len(waits) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, w := range waits {
+		total += w
+	}
+	if left := total/time.Duration(len(waits)) - waited; left > 0 {
+		return left
+	}
+	return 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,262 @@
+package game
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when the test advances it, Tick hands out a channel the test sends on
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	ticks chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), ticks: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Tick(time.Duration) <-chan time.Time { return c.ticks }
+
+func (c *fakeClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+type queued struct {
+	user   uint
+	rating int
+	mode   string
+}
+
+// mmStep moves the clock to at after the start, adds and removes players, then ticks
+type mmStep struct {
+	at     time.Duration
+	add    []queued
+	remove []uint
+}
+
+type statusUpdate struct {
+	user   uint
+	status QueueStatus
+}
+
+func TestMatchmakerTick(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []mmStep
+		// statusEvery is the config's StatusInterval, by default long enough to never fire
+		statusEvery time.Duration
+		pairs       [][2]uint
+		statuses    []statusUpdate
+		waiting     int
+	}{
+		{
+			name:  "pairs within the base band",
+			steps: []mmStep{{at: 0, add: []queued{{1, 1500, "classic"}, {2, 1580, "classic"}}}},
+			pairs: [][2]uint{{1, 2}},
+		},
+		{
+			name: "band widens until the ratings overlap",
+			steps: []mmStep{
+				{at: 0, add: []queued{{1, 1000, "classic"}, {2, 1300, "classic"}}},
+				{at: 10 * time.Second},
+				{at: 39 * time.Second},
+				{at: 40 * time.Second},
+			},
+			pairs: [][2]uint{{1, 2}},
+		},
+		{
+			name: "band stops growing at the maximum",
+			steps: []mmStep{
+				{at: 0, add: []queued{{1, 1000, "classic"}, {2, 2100, "classic"}}},
+				{at: time.Hour},
+			},
+			waiting: 2,
+		},
+		{
+			name: "the wider band of the two decides",
+			steps: []mmStep{
+				{at: 0, add: []queued{{1, 1000, "classic"}}},
+				{at: 40 * time.Second, add: []queued{{2, 1300, "classic"}}},
+			},
+			pairs: [][2]uint{{1, 2}},
+		},
+		{
+			name:    "closest rating is picked",
+			steps:   []mmStep{{at: 0, add: []queued{{1, 1500, "classic"}, {2, 1580, "classic"}, {3, 1510, "classic"}}}},
+			pairs:   [][2]uint{{1, 3}},
+			waiting: 1,
+		},
+		{
+			name:    "modes never mix",
+			steps:   []mmStep{{at: 0, add: []queued{{1, 1500, "classic"}, {2, 1500, "blitz"}}}},
+			waiting: 2,
+		},
+		{
+			name: "a player who left is not paired",
+			steps: []mmStep{
+				{at: 0, add: []queued{{1, 1000, "classic"}, {2, 1300, "classic"}}},
+				{at: 30 * time.Second, remove: []uint{2}},
+				{at: 40 * time.Second, add: []queued{{3, 1250, "classic"}}},
+			},
+			pairs: [][2]uint{{1, 3}},
+		},
+		{
+			name: "no immediate rematch",
+			steps: []mmStep{
+				{at: 0, add: []queued{{1, 1500, "classic"}, {2, 1500, "classic"}}},
+				{at: time.Second, add: []queued{{1, 1500, "classic"}, {2, 1500, "classic"}}},
+				{at: 60 * time.Second},
+				{at: 61 * time.Second},
+			},
+			pairs: [][2]uint{{1, 2}, {1, 2}},
+		},
+		{
+			name:        "waiting players get status updates",
+			statusEvery: 5 * time.Second,
+			steps: []mmStep{
+				{at: 0, add: []queued{{1, 1000, "classic"}}},
+				{at: 4 * time.Second},
+				{at: 5 * time.Second},
+				{at: 9 * time.Second},
+				{at: 10 * time.Second},
+			},
+			statuses: []statusUpdate{
+				{1, QueueStatus{WaitedSeconds: 5, Band: 100, Waiting: 1}},
+				{1, QueueStatus{WaitedSeconds: 10, Band: 150, Waiting: 1}},
+			},
+			waiting: 1,
+		},
+		{
+			name:        "status estimates from recent waits",
+			statusEvery: 5 * time.Second,
+			steps: []mmStep{
+				{at: 0, add: []queued{{1, 1000, "classic"}, {2, 1100, "classic"}}},
+				{at: 20 * time.Second, add: []queued{{3, 1000, "classic"}, {4, 1200, "classic"}}},
+				{at: 25 * time.Second},
+				{at: 40 * time.Second},
+			},
+			pairs: [][2]uint{{1, 2}, {3, 4}},
+			statuses: []statusUpdate{
+				// Players 1 and 2 waited 0s, so there's nothing left to estimate
+				{3, QueueStatus{WaitedSeconds: 5, Band: 100, Waiting: 2}},
+				{4, QueueStatus{WaitedSeconds: 5, Band: 100, Waiting: 2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			start := clock.Now()
+
+			config := DefaultMatchmakerConfig()
+			config.StatusInterval = 24 * time.Hour
+			if tt.statusEvery > 0 {
+				config.StatusInterval = tt.statusEvery
+			}
+
+			var pairs [][2]uint
+			var statuses []statusUpdate
+			mm := NewMatchmaker(clock, config,
+				func(a, b *Player) { pairs = append(pairs, [2]uint{a.UserID, b.UserID}) },
+				func(p *Player, status QueueStatus) { statuses = append(statuses, statusUpdate{p.UserID, status}) })
+
+			players := map[uint]*Player{}
+			for _, step := range tt.steps {
+				clock.set(start.Add(step.at))
+				for _, q := range step.add {
+					p := &Player{UserID: q.user, rating: q.rating, mode: q.mode}
+					players[q.user] = p
+					mm.Add(p)
+				}
+				for _, user := range step.remove {
+					if !mm.Remove(players[user]) {
+						t.Fatalf("user %d was not waiting", user)
+					}
+				}
+				mm.Tick()
+			}
+
+			if !reflect.DeepEqual(pairs, tt.pairs) {
+				t.Errorf("pairs = %v, want %v", pairs, tt.pairs)
+			}
+			if !reflect.DeepEqual(statuses, tt.statuses) {
+				t.Errorf("statuses = %+v, want %+v", statuses, tt.statuses)
+			}
+			if got := mm.Waiting(); got != tt.waiting {
+				t.Errorf("waiting = %d, want %d", got, tt.waiting)
+			}
+		})
+	}
+}
+
+func TestMatchmakerEstimate(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+
+	config := DefaultMatchmakerConfig()
+	config.StatusInterval = 5 * time.Second
+	var last QueueStatus
+	mm := NewMatchmaker(clock, config, func(a, b *Player) {}, func(p *Player, status QueueStatus) { last = status })
+
+	// Two players who took 40s to be paired
+	mm.Add(&Player{UserID: 1, rating: 1000, mode: "classic"})
+	mm.Add(&Player{UserID: 2, rating: 1300, mode: "classic"})
+	clock.set(start.Add(40 * time.Second))
+	mm.Tick()
+
+	mm.Add(&Player{UserID: 3, rating: 3000, mode: "classic"})
+	clock.set(start.Add(50 * time.Second))
+	mm.Tick()
+
+	if last.WaitedSeconds != 10 || last.EstimateSeconds != 30 {
+		t.Errorf("status = %+v, want 10s waited and 30s left", last)
+	}
+}
+
+func TestMatchmakerAddTwiceAndRemove(t *testing.T) {
+	mm := NewMatchmaker(newFakeClock(), DefaultMatchmakerConfig(), func(a, b *Player) {}, func(p *Player, status QueueStatus) {})
+	p := &Player{UserID: 1, rating: 1500, mode: "classic"}
+
+	mm.Add(p)
+	mm.Add(p)
+	if got := mm.Waiting(); got != 1 {
+		t.Fatalf("waiting = %d after adding twice, want 1", got)
+	}
+	if !mm.Remove(p) || mm.Remove(p) {
+		t.Error("Remove should report true once and false after")
+	}
+}
+
+func TestMatchmakerStartTicksOnTheClock(t *testing.T) {
+	clock := newFakeClock()
+	paired := make(chan [2]uint, 1)
+	mm := NewMatchmaker(clock, DefaultMatchmakerConfig(),
+		func(a, b *Player) { paired <- [2]uint{a.UserID, b.UserID} },
+		func(p *Player, status QueueStatus) {})
+	mm.Start()
+
+	mm.Add(&Player{UserID: 1, rating: 1500, mode: "classic"})
+	mm.Add(&Player{UserID: 2, rating: 1500, mode: "classic"})
+	clock.ticks <- clock.Now()
+
+	select {
+	case got := <-paired:
+		if got != [2]uint{1, 2} {
+			t.Errorf("paired %v, want [1 2]", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pair after a tick")
+	}
+}
@@ -0,0 +1,124 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// ReconnectGraceFromEnv reads RECONNECT_GRACE_SECONDS, how long a player who dropped out of a match
+// has to reconnect before forfeiting it (default 30, 0 forfeits right away)
+func ReconnectGraceFromEnv() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("RECONNECT_GRACE_SECONDS")); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// ResumeState is sent to a player who reconnected, with what they did in the match so far
+type ResumeState struct {
+	Submissions   []modles.MatchSubmission `json:"submissions"`
+	BestPassed    int                      `json:"best_passed"`
+	Score         float64                  `json:"score"`
+	OpponentScore float64                  `json:"opponent_score"`
+}
+
+// CanResume reports whether userID holds a seat in an open match that a new connection
+// would take back, reconnecting to it isn't a new game
+func (rm *Room) CanResume(userID uint) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	player := rm.players[userID]
+	return player != nil && !player.closed && player.partner != nil && !player.solved
+}
+
+// rejoin puts userID back in their seat with conn when they hold one in an open match, the newest
+// connection wins so a drop the server hasn't noticed yet doesn't lock the player out.
+// Reports whether conn was taken, otherwise it is a new player.
+func (rm *Room) rejoin(userID uint, conn *websocket.Conn) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	player := rm.players[userID]
+	if player == nil || player.closed || player.partner == nil || player.solved {
+		return false
+	}
+
+	if player.reconnect != nil {
+		player.reconnect.Stop()
+		player.reconnect = nil
+	}
+
+	player.connMu.Lock()
+	old, wasDisconnected := player.conn, player.disconnected
+	player.conn = conn
+	player.disconnected = false
+	player.connMu.Unlock()
+	if !wasDisconnected {
+		// Its reader fails and is ignored as a stale connection
+		old.Close()
+	}
+
+	problem := rm.currentProblems[userID]
+	problemMsg := Message{
+		Type:    "problem",
+		Status:  "resumed",
+		Msg:     "Reconnected! Your match goes on:",
+		Problem: publicProblem(&problem),
+		Mode:    player.mode,
+
+		RemainingSeconds: int(player.timer.remaining() / time.Second),
+	}
+	problemJSON, _ := json.Marshal(problemMsg)
+	player.send <- problemJSON
+
+	state := ResumeState{BestPassed: player.bestPassed, Score: player.bestScore, OpponentScore: player.partner.bestScore}
+	if player.matchID != 0 {
+		subs, err := database.ListMatchSubmissions(rm.db, player.matchID)
+		if err != nil {
+			fmt.Println(err)
+		}
+		for _, sub := range subs {
+			if sub.UserID == userID {
+				state.Submissions = append(state.Submissions, sub)
+			}
+		}
+	}
+	resumeJSON, _ := json.Marshal(Message{
+		Type:   "resume",
+		Status: "resumed",
+		Result: state,
+	})
+	player.send <- resumeJSON
+
+	rm.notifyPartner(player, Message{
+		Type:   "opponent_status",
+		Status: "reconnected",
+		Msg:    "Opponent reconnected",
+	})
+
+	go rm.ListenForSolutions(player, conn)
+
+	fmt.Printf("Player %d reconnected to match %d\n", userID, player.matchID)
+	return true
+}
+
+// notifyPartner sends msg to player's opponent if they're still there. Caller holds rm.mu.
+func (rm *Room) notifyPartner(player *Player, msg Message) {
+	partner := player.partner
+	if partner == nil || partner.closed {
+		return
+	}
+	msgJSON, _ := json.Marshal(msg)
+	select {
+	case partner.send <- msgJSON:
+	default:
+	}
+}
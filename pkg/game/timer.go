@@ -0,0 +1,188 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// timerBroadcastInterval is how often both players are told the time left
+const timerBroadcastInterval = 15 * time.Second
+
+// MatchDurations is how long a match lasts by problem difficulty
+type MatchDurations struct {
+	Easy   time.Duration
+	Medium time.Duration
+	Hard   time.Duration
+}
+
+// MatchDurationsFromEnv reads MATCH_MINUTES_EASY, MATCH_MINUTES_MEDIUM and MATCH_MINUTES_HARD,
+// 15, 30 and 45 minutes by default
+func MatchDurationsFromEnv() MatchDurations {
+	minutes := func(name string, fallback int) time.Duration {
+		if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+			return time.Duration(n) * time.Minute
+		}
+		return time.Duration(fallback) * time.Minute
+	}
+	return MatchDurations{
+		Easy:   minutes("MATCH_MINUTES_EASY", 15),
+		Medium: minutes("MATCH_MINUTES_MEDIUM", 30),
+		Hard:   minutes("MATCH_MINUTES_HARD", 45),
+	}
+}
+
+// For is the duration of a match on a problem of difficulty, unknown ones count as medium
+func (d MatchDurations) For(difficulty string) time.Duration {
+	switch difficulty {
+	case "easy":
+		return d.Easy
+	case "hard":
+		return d.Hard
+	default:
+		return d.Medium
+	}
+}
+
+// matchTimer is the clock of one match, shared by both players
+type matchTimer struct {
+	deadline time.Time
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func newMatchTimer(duration time.Duration) *matchTimer {
+	return &matchTimer{deadline: time.Now().Add(duration), stop: make(chan struct{})}
+}
+
+// Stop cancels the timer, safe to call any number of times
+func (t *matchTimer) Stop() {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() { close(t.stop) })
+}
+
+// remaining is the time left, never negative
+func (t *matchTimer) remaining() time.Duration {
+	if left := time.Until(t.deadline); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// runTimer broadcasts the time left to both players until the match ends or time runs out
+func (rm *Room) runTimer(a, b *Player, timer *matchTimer) {
+	ticker := time.NewTicker(timerBroadcastInterval)
+	defer ticker.Stop()
+	expired := time.NewTimer(timer.remaining())
+	defer expired.Stop()
+
+	for {
+		select {
+		case <-timer.stop:
+			return
+		case <-ticker.C:
+			rm.broadcastTime(a, b, timer)
+		case <-expired.C:
+			rm.handleTimeout(a, b, timer)
+			return
+		}
+	}
+}
+
+func (rm *Room) broadcastTime(a, b *Player, timer *matchTimer) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	left := timer.remaining()
+	timerMsg := Message{
+		Type:             "timer",
+		Status:           "running",
+		Msg:              fmt.Sprintf("%d:%02d left", int(left.Minutes()), int(left.Seconds())%60),
+		RemainingSeconds: int(left / time.Second),
+	}
+	timerJSON, _ := json.Marshal(timerMsg)
+	for _, p := range []*Player{a, b} {
+		if p.closed || p.timer != timer {
+			continue
+		}
+		select {
+		case p.send <- timerJSON:
+		default:
+		}
+	}
+}
+
+// handleTimeout ends a match whose time ran out: whoever passed more test cases in a row
+// from the first (scored more in points mode) wins, a tie is a draw
+func (rm *Room) handleTimeout(a, b *Player, timer *matchTimer) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	// The match may have ended some other way just as time ran out
+	if a.timer != timer || a.partner != b || a.solved || b.solved {
+		return
+	}
+	a.solved = true
+	b.solved = true
+
+	progress := func(p *Player) float64 {
+		if p.mode == ModePoints {
+			return p.bestScore
+		}
+		return float64(p.bestPassed)
+	}
+
+	if progress(a) == progress(b) {
+		match := rm.updatePlayerRating(a, b, modles.MatchDraw, modles.EndTimeout)
+		for _, p := range []*Player{a, b} {
+			drawMsg := Message{
+				Type:        "game_end",
+				Status:      "draw",
+				Msg:         "Time's up! It's a draw.",
+				RatingDelta: ratingDelta(match, p.UserID),
+			}
+			drawJSON, _ := json.Marshal(drawMsg)
+			p.send <- drawJSON
+		}
+		fmt.Println("Match timed out - draw")
+	} else {
+		winner, loser := a, b
+		if progress(b) > progress(a) {
+			winner, loser = b, a
+		}
+		match := rm.updatePlayerRating(winner, loser, modles.MatchWin, modles.EndTimeout)
+
+		winMsg := Message{
+			Type:        "game_end",
+			Status:      "win",
+			Msg:         "Time's up! You won, you got further than your opponent.",
+			RatingDelta: ratingDelta(match, winner.UserID),
+		}
+		winJSON, _ := json.Marshal(winMsg)
+		winner.send <- winJSON
+
+		loseMsg := Message{
+			Type:        "game_end",
+			Status:      "lose",
+			Msg:         "Time's up! You lost, your opponent got further.",
+			RatingDelta: ratingDelta(match, loser.UserID),
+		}
+		loseJSON, _ := json.Marshal(loseMsg)
+		loser.send <- loseJSON
+		fmt.Println("Match timed out - winner determined")
+	}
+
+	go func() {
+		rm.mu.Lock()
+		defer rm.mu.Unlock()
+		rm.CleanupPlayers(a)
+		rm.CleanupPlayers(b)
+	}()
+}
@@ -14,6 +14,9 @@ type contextKey string
 
 const UserContextKey contextKey = "user"
 
+// RoleAdmin is the user role allowed on admin routes
+const RoleAdmin = "admin"
+
 type AuthMiddleware struct {
 	Db *database.Databse
 }
@@ -100,6 +103,26 @@ func (am *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	})
 }
 
+// RequireAdmin lets only users whose role is "admin" in the database through,
+// the role in the token is not trusted on its own
+func (am *AuthMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return am.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		userContext, _ := GetUserFromContext(r)
+
+		var user modles.User
+		if err := am.Db.Db.Select("role").Where("id = ?", userContext.UserID).First(&user).Error; err != nil || user.Role != RoleAdmin {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(Response{
+				Success: false,
+				Message: "Admin access required",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 //Helper function to get user from context (for use in handlers)
 func GetUserFromContext(r *http.Request) (*UserContext, bool) {
 	user, ok := r.Context().Value(UserContextKey).(UserContext)
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iAmImran007/Code_War/pkg/database"
+)
+
+// RateLimiter allows each user a fixed number of requests per window. Every limiter has
+// its own name so different actions (custom runs, submissions) are counted separately.
+// Counters live in Redis so all servers share them, in memory when Redis is down.
+type RateLimiter struct {
+	db     *database.Databse
+	name   string
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	counts map[uint]*windowCount
+}
+
+type windowCount struct {
+	count   int
+	resetAt time.Time
+}
+
+func NewRateLimiter(db *database.Databse, name string, limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		db:     db,
+		name:   name,
+		limit:  limit,
+		window: window,
+		counts: make(map[uint]*windowCount),
+	}
+}
+
+// RunRateLimitFromEnv reads RUN_RATE_LIMIT, the custom runs a user may start per minute
+func RunRateLimitFromEnv() int {
+	if n, err := strconv.Atoi(os.Getenv("RUN_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return 10
+}
+
+// Allow counts a request by userID and reports whether it is within the limit
+func (rl *RateLimiter) Allow(userID uint) bool {
+	if rl.db.Cache != nil {
+		count, err := rl.db.Cache.IncrementRateCounter(fmt.Sprintf("%s_%d", rl.name, userID), rl.window)
+		if err == nil {
+			return count <= int64(rl.limit)
+		}
+		fmt.Printf("Rate limiter %s falling back to memory: %v\n", rl.name, err)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	c, ok := rl.counts[userID]
+	if !ok || now.After(c.resetAt) {
+		// Drop expired windows while we hold the lock anyway
+		for id, old := range rl.counts {
+			if now.After(old.resetAt) {
+				delete(rl.counts, id)
+			}
+		}
+		c = &windowCount{resetAt: now.Add(rl.window)}
+		rl.counts[userID] = c
+	}
+	c.count++
+	return c.count <= rl.limit
+}
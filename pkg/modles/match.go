@@ -0,0 +1,113 @@
+package modles
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Match is one ranked battle between two players on a problem
+type Match struct {
+	gorm.Model
+	ProblemID uint   `json:"problem_id" gorm:"index"`
+	Mode      string `json:"mode"`
+	Player1ID uint   `json:"player1_id" gorm:"index"`
+	Player2ID uint   `json:"player2_id" gorm:"index"`
+	// WinnerID is 0 until the match is decided and for a draw
+	WinnerID uint `json:"winner_id"`
+	// Result is how the match was decided, empty while it is still open
+	Result    string    `json:"result"`
+	StartedAt time.Time `json:"started_at"`
+	// EndedAt is nil while the match is open
+	EndedAt *time.Time `json:"ended_at,omitempty"`
+	// EndReason is what ended the match: solved, disconnect, timeout or surrender
+	EndReason string `json:"end_reason"`
+
+	// The rating changes applied when the match ended, kept so the result can be reverted
+	Player1RatingDelta int        `json:"player1_rating_delta"`
+	Player2RatingDelta int        `json:"player2_rating_delta"`
+	Reverted           bool       `json:"reverted"`
+	RevertedAt         *time.Time `json:"reverted_at,omitempty"`
+
+	Submissions []MatchSubmission `json:"submissions,omitempty" gorm:"foreignKey:MatchID"`
+}
+
+// Results of a Match
+const (
+	MatchWin  = "win"
+	MatchDraw = "draw"
+	// MatchForfeit is won by the player who stayed when the other left or gave up
+	MatchForfeit = "forfeit"
+)
+
+// What ended a Match
+const (
+	EndSolved     = "solved"
+	EndDisconnect = "disconnect"
+	EndTimeout    = "timeout"
+	EndSurrender  = "surrender"
+)
+
+// MatchSubmission is code a player submitted during a match
+type MatchSubmission struct {
+	gorm.Model
+	MatchID   uint    `json:"match_id" gorm:"index"`
+	ProblemID uint    `json:"problem_id" gorm:"index"`
+	UserID    uint    `json:"user_id" gorm:"index"`
+	Language  string  `json:"language"`
+	Code      string  `json:"code"`
+	Verdict   string  `json:"verdict"`
+	Score     float64 `json:"score"`
+	// Fingerprint holds the winnowed hashes of the normalized code, filled in for accepted submissions
+	Fingerprint string `json:"-"`
+}
+
+// Review states of a SimilarityFlag
+const (
+	FlagPending   = "pending"
+	FlagDismissed = "dismissed"
+	FlagReverted  = "reverted"
+)
+
+// SimilarityFlag is an accepted submission that looks copied, waiting for an admin's review
+type SimilarityFlag struct {
+	gorm.Model
+	ProblemID    uint `json:"problem_id" gorm:"index"`
+	MatchID      uint `json:"match_id" gorm:"index"`
+	SubmissionID uint `json:"submission_id"`
+	UserID       uint `json:"user_id"`
+	// OtherSubmissionID is 0 when the code matched the problem's reference solution
+	OtherSubmissionID uint    `json:"other_submission_id"`
+	OtherUserID       uint    `json:"other_user_id"`
+	Score             float64 `json:"score"`
+	Status            string  `json:"status" gorm:"default:pending;index"`
+	ReviewedBy        uint    `json:"reviewed_by,omitempty"`
+}
+
+// Outcomes of a Hack
+const (
+	HackSuccess = "success"
+	HackFailed  = "failed"
+	HackInvalid = "invalid"
+)
+
+// Hack is a test input a player submitted against the opponent's accepted code after a match
+type Hack struct {
+	gorm.Model
+	MatchID    uint `json:"match_id" gorm:"index"`
+	ProblemID  uint `json:"problem_id" gorm:"index"`
+	HackerID   uint `json:"hacker_id" gorm:"index"`
+	DefenderID uint `json:"defender_id"`
+	// SubmissionID is the defender's MatchSubmission the input was run against
+	SubmissionID   uint   `json:"submission_id" gorm:"index"`
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+	Outcome        string `json:"outcome" gorm:"index"`
+	// Verdict is what the defender's code got on the input, Message why an invalid input was rejected
+	Verdict string `json:"verdict"`
+	Message string `json:"message"`
+	// Points went to the hacker, negative for a failed hack
+	Points int `json:"points"`
+	// PromotedTestID is the test case a successful hack became, 0 until promoted
+	PromotedTestID uint `json:"promoted_test_id"`
+}
@@ -12,14 +12,57 @@ type Example struct {
 
 type ProblemPropaty struct {
 	gorm.Model
-	Title       string            `json:"title"`
-	Description string            `json:"description"`
-	HaderFile   string            `json:"hader_file"`
-	FuncBody    string            `json:"func_body"`
-	MainFunc    string            `json:"main_func"`
-	TestCases   []TestCaesPropaty `json:"test_cases" gorm:"foreignKey:ProblemID"`
-	Difficulty  string            `json:"difficulty"` // "easy", "medium", "hard"
-	Examples    []Example         `json:"examples" gorm:"foreignKey:ProblemID"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	HaderFile   string             `json:"hader_file"`
+	FuncBody    string             `json:"func_body"`
+	MainFunc    string             `json:"main_func"`
+	TestCases   []TestCaesPropaty  `json:"test_cases" gorm:"foreignKey:ProblemID"`
+	Difficulty  string             `json:"difficulty"` // "easy", "medium", "hard"
+	Examples    []Example          `json:"examples" gorm:"foreignKey:ProblemID"`
+	Templates   []LanguageTemplate `json:"templates" gorm:"foreignKey:ProblemID"`
+	Subtasks    []Subtask          `json:"subtasks" gorm:"foreignKey:ProblemID"`
+
+	// Checker is "exact" (default), "tokens", "lines", "case_insensitive", "float" or "custom"
+	Checker        string  `json:"checker" gorm:"default:exact"`
+	FloatTolerance float64 `json:"float_tolerance"`
+	// CheckerSource is a testlib-style C++ checker used when Checker is "custom"
+	CheckerSource string `json:"checker_source"`
+
+	// Interactive problems run InteractorSource (C++) against the submission over stdin/stdout,
+	// the test case input goes to the interactor and its exit code is the verdict
+	Interactive      bool   `json:"interactive"`
+	InteractorSource string `json:"interactor_source"`
+
+	// Resource limits, 0 falls back to the server defaults.
+	// Time and memory are scaled per language before the judge enforces them.
+	TimeLimitMs   int64 `json:"time_limit_ms"`
+	MemoryLimitMB int64 `json:"memory_limit_mb"`
+	OutputLimitKB int64 `json:"output_limit_kb"`
+	StackLimitMB  int64 `json:"stack_limit_mb"`
+
+	// ReferenceSource is a known correct solution written like a submission in ReferenceLanguage,
+	// GeneratorSource a C++ program printing a random test input for the seed in its first argument.
+	// With a reference the judge also computes the expected outputs of the test cases itself.
+	ReferenceLanguage string `json:"reference_language"`
+	ReferenceSource   string `json:"reference_source"`
+	GeneratorSource   string `json:"generator_source"`
+	// ValidatorSource is a C++ program reading a test input on stdin and exiting 0 when it's
+	// valid, players can hack an opponent's solution only on problems that have one
+	ValidatorSource string `json:"validator_source"`
+	// ReferenceChecksum covers the reference, limits and test inputs the expected outputs were generated with
+	ReferenceChecksum string `json:"reference_checksum"`
+}
+
+// LanguageTemplate holds the header, starter stub and main for one language of a problem
+type LanguageTemplate struct {
+	gorm.Model
+	ProblemID uint            `json:"problem_id" gorm:"index"`
+	Language  string          `json:"language"`
+	HaderFile string          `json:"hader_file"`
+	FuncBody  string          `json:"func_body"`
+	MainFunc  string          `json:"main_func"`
+	Problem   *ProblemPropaty `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
 }
 
 type TestCaesPropaty struct {
@@ -28,6 +71,30 @@ type TestCaesPropaty struct {
 	ExpectedOutput string          `json:"expected_output"`
 	ProblemID      uint            `json:"problem_id" gorm:"index"`
 	Problem        *ProblemPropaty `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+
+	// Group is the subtask the test belongs to, 0 when the problem has no subtasks
+	Group int `json:"group"`
+	// Weight is the test's share of its group (or of the problem without subtasks), 0 counts as 1
+	Weight float64 `json:"weight"`
+
+	// ReferenceVerdict is how the reference solution did on the test when its expected output was
+	// generated, anything but "OK" means the test needs the author's attention
+	ReferenceVerdict string `json:"reference_verdict"`
+	ReferenceTimeMs  int64  `json:"reference_time_ms"`
+}
+
+// Subtask gives the test cases of one group a point value and a scoring policy,
+// the points of all subtasks of a problem add up to 100
+type Subtask struct {
+	gorm.Model
+	ProblemID uint    `json:"problem_id" gorm:"index"`
+	Group     int     `json:"group"`
+	Points    float64 `json:"points"`
+	// Policy is "min" (every test of the group must pass) or "sum" (points split by test weight)
+	Policy string `json:"policy" gorm:"default:min"`
+	// DependsOn lists earlier groups, comma separated, that must be fully solved for this one to score
+	DependsOn string          `json:"depends_on"`
+	Problem   *ProblemPropaty `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
 }
 
 /*
@@ -36,3 +103,19 @@ docker-compose build      # rebuilds all ima
 docker-compose up --build
 
 */
+
+// CounterExample is a generated input on which a candidate solution disagreed with the
+// problem's reference solution, found by a stress test and waiting to become a test case
+type CounterExample struct {
+	gorm.Model
+	ProblemID      uint   `json:"problem_id" gorm:"index"`
+	Language       string `json:"language"`
+	Seed           int64  `json:"seed"`
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+	// Verdict is what the candidate got on the input
+	Verdict string `json:"verdict"`
+	// PromotedTestID is the TestCaesPropaty created from it, 0 until promoted
+	PromotedTestID uint            `json:"promoted_test_id"`
+	Problem        *ProblemPropaty `json:"problem,omitempty" gorm:"foreignKey:ProblemID"`
+}
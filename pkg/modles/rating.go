@@ -0,0 +1,19 @@
+package modles
+
+import "gorm.io/gorm"
+
+// RatingHistory is one player's rating change from one match
+type RatingHistory struct {
+	gorm.Model
+	UserID     uint `json:"user_id" gorm:"index"`
+	MatchID    uint `json:"match_id" gorm:"index"`
+	OpponentID uint `json:"opponent_id"`
+	// Result is "win", "loss" or "draw" from the player's side
+	Result string `json:"result"`
+	// Forfeit is set when the match was decided by a player leaving
+	Forfeit        bool `json:"forfeit"`
+	RatingBefore   int  `json:"rating_before"`
+	RatingAfter    int  `json:"rating_after"`
+	Delta          int  `json:"delta"`
+	OpponentRating int  `json:"opponent_rating"`
+}
@@ -11,8 +11,10 @@ type User struct {
 	Email     string    `json:"email" db:"email"`
 	Password  string    `json:"-" db:"password_hash"`
 	Role      string    `gorm:"default:user" json:"role" db:"role"`
-	Rating int `gorm:"default:0" json:"rating" db:"rating"`
+	Rating int `gorm:"default:1200" json:"rating" db:"rating"`
+	GamesPlayed int `gorm:"default:0" json:"games_played" db:"games_played"`
 	SolvedProblems int `gorm:"default:0" json:"solved_problems" db:"solved_problems"`
+	HackPoints int `gorm:"default:0" json:"hack_points" db:"hack_points"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,47 @@
+// Package rating is the Elo rating engine behind ranked matches
+package rating
+
+import "math"
+
+// InitialRating is where every player starts
+const InitialRating = 1200
+
+// Scores of a match from one player's side
+const (
+	Win  = 1.0
+	Draw = 0.5
+	Loss = 0.0
+)
+
+// Player is what a rating change depends on
+type Player struct {
+	Rating int
+	// Games is the number of rated matches played before this one
+	Games int
+}
+
+// KFactor is how far one match moves a rating: new players settle quickly,
+// established ones move less and the top of the ladder least
+func KFactor(p Player) float64 {
+	switch {
+	case p.Games < 30:
+		return 40
+	case p.Rating >= 2400:
+		return 10
+	default:
+		return 20
+	}
+}
+
+// Expected is a's expected score against b, between 0 and 1
+func Expected(a, b int) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
+
+// Deltas returns the rating changes of a and b after a match where a scored score (Win, Draw or Loss).
+// Each side moves by its own K-factor, so the changes don't always cancel out.
+func Deltas(a, b Player, score float64) (int, int) {
+	da := KFactor(a) * (score - Expected(a.Rating, b.Rating))
+	db := KFactor(b) * ((1 - score) - Expected(b.Rating, a.Rating))
+	return int(math.Round(da)), int(math.Round(db))
+}
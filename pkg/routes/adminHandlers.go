@@ -0,0 +1,298 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+type StressRequest struct {
+	Code     string `json:"code"`
+	Language string `json:"language"`
+	Runs     int    `json:"runs"`
+	// Seed is the first generator seed, a time based one is picked when left out
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// ReferenceRequest changes a problem's reference solution and limits, fields left out stay as they are
+type ReferenceRequest struct {
+	ReferenceLanguage *string `json:"reference_language,omitempty"`
+	ReferenceSource   *string `json:"reference_source,omitempty"`
+	ValidatorSource   *string `json:"validator_source,omitempty"`
+	TimeLimitMs       *int64  `json:"time_limit_ms,omitempty"`
+	MemoryLimitMB     *int64  `json:"memory_limit_mb,omitempty"`
+	OutputLimitKB     *int64  `json:"output_limit_kb,omitempty"`
+	StackLimitMB      *int64  `json:"stack_limit_mb,omitempty"`
+}
+
+type PromoteRequest struct {
+	// Group is the subtask group the new test case joins, 0 without subtasks
+	Group int `json:"group"`
+}
+
+// HandleStressTest - POST /admin/stress/{id} (Admin route)
+// Stress tests a candidate solution against the problem's reference on generated inputs,
+// a counter-example is stored so it can be promoted to a test case
+func (r *Routes) HandleStressTest(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	problemID, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid problem ID format",
+		})
+		return
+	}
+
+	// Limit request body size (1MB)
+	req.Body = http.MaxBytesReader(w, req.Body, 1048576)
+
+	var stressReq StressRequest
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&stressReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if strings.TrimSpace(stressReq.Code) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Code cannot be empty",
+		})
+		return
+	}
+
+	if stressReq.Runs == 0 {
+		stressReq.Runs = 100
+	}
+	if stressReq.Runs < 0 || stressReq.Runs > cppruner.MaxStressRuns {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: fmt.Sprintf("Runs must be between 1 and %d", cppruner.MaxStressRuns),
+		})
+		return
+	}
+
+	seed := time.Now().UnixNano() % 1000000000
+	if stressReq.Seed != nil {
+		seed = *stressReq.Seed
+	}
+
+	report, err := cppruner.StressTestOn(r.Judge, uint(problemID), stressReq.Language, stressReq.Code, stressReq.Runs, seed)
+	if err != nil {
+		// Setup problems (no reference, a failing generator, a compile error) are the author's to fix
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: fmt.Sprintf("Stress test failed: %v", err),
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"report": report,
+	}
+	message := fmt.Sprintf("No counter-example in %d runs", report.Runs)
+
+	if report.CounterExample != nil {
+		ce, err := cppruner.RecordCounterExample(r.Db, report)
+		if err != nil {
+			fmt.Printf("Failed to record counter-example: %v\n", err)
+		} else {
+			data["counter_example_id"] = ce.ID
+		}
+		message = fmt.Sprintf("Counter-example found on seed %d", report.CounterExample.Seed)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: message,
+		Data:    data,
+	})
+}
+
+// HandlePromoteCounterExample - POST /admin/counterexamples/{id}/promote (Admin route)
+// Adds a stored counter-example to its problem's test cases
+func (r *Routes) HandlePromoteCounterExample(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	id, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid counter-example ID format",
+		})
+		return
+	}
+
+	// The body is optional, an empty one promotes into group 0
+	var promoteReq PromoteRequest
+	req.Body = http.MaxBytesReader(w, req.Body, 4096)
+	if err := json.NewDecoder(req.Body).Decode(&promoteReq); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	testCase, err := database.PromoteCounterExample(r.Db, uint(id), promoteReq.Group)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Counter-example added to the test cases",
+		Data: map[string]interface{}{
+			"test_case_id": testCase.ID,
+			"problem_id":   testCase.ProblemID,
+		},
+	})
+}
+
+// HandleUpdateReference - PUT /admin/problems/{id}/reference (Admin route)
+// Updates the reference solution and limits, then regenerates the expected outputs of every
+// test case. An empty body only regenerates them.
+func (r *Routes) HandleUpdateReference(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	problemID, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid problem ID format",
+		})
+		return
+	}
+
+	// Limit request body size (1MB)
+	req.Body = http.MaxBytesReader(w, req.Body, 1048576)
+
+	var refReq ReferenceRequest
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&refReq); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if refReq.ReferenceLanguage != nil {
+		if _, err := cppruner.GetLanguage(*refReq.ReferenceLanguage); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(Response{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		updates["reference_language"] = *refReq.ReferenceLanguage
+	}
+	if refReq.ReferenceSource != nil {
+		updates["reference_source"] = *refReq.ReferenceSource
+	}
+	// The validator only gates hack inputs, it doesn't change any expected output
+	if refReq.ValidatorSource != nil {
+		updates["validator_source"] = *refReq.ValidatorSource
+	}
+	// 0 falls back to the server default, like in the problem itself
+	for column, limit := range map[string]*int64{
+		"time_limit_ms":   refReq.TimeLimitMs,
+		"memory_limit_mb": refReq.MemoryLimitMB,
+		"output_limit_kb": refReq.OutputLimitKB,
+		"stack_limit_mb":  refReq.StackLimitMB,
+	} {
+		if limit == nil {
+			continue
+		}
+		if *limit < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(Response{
+				Success: false,
+				Message: fmt.Sprintf("%s cannot be negative", column),
+			})
+			return
+		}
+		updates[column] = *limit
+	}
+
+	if len(updates) > 0 {
+		result := r.Db.Db.Model(&modles.ProblemPropaty{}).Where("id = ?", problemID).Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(Response{
+				Success: false,
+				Message: "Problem not found",
+			})
+			return
+		}
+	}
+
+	report, err := cppruner.GenerateExpectedOutputsOn(r.Judge, uint(problemID), r.Db)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to generate expected outputs: %v", err),
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Expected outputs of %d test cases regenerated", len(report.Tests))
+	if report.Flagged > 0 {
+		message = fmt.Sprintf("%s, %d flagged", message, report.Flagged)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: message,
+		Data:    report,
+	})
+}
@@ -625,6 +625,7 @@ func (r *Routes) handleProfile(w http.ResponseWriter, req *http.Request) {
 			"email":   user.Email,
 			"role":    user.Role,
 			"rating": user.Rating,
+			"games_played": user.GamesPlayed,
 			"solved_problems": user.SolvedProblems,
 		},
 	})
@@ -0,0 +1,534 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/middleware"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// Hack points, a failed hack costs a little so inputs aren't thrown around blindly
+const (
+	hackSuccessPoints = 100
+	hackFailedPoints  = -25
+)
+
+// postMatchWindow is how long after a match its players may still submit, a player who
+// didn't get accepted in time can unlock hacking that way
+const postMatchWindow = time.Hour
+
+type HackRequest struct {
+	Input string `json:"input"`
+}
+
+// HandleMatchSubmission - POST /matches/{id}/submissions (Protected route)
+// Judges a submission made after the match ended, it counts for hacking but not for the result
+func (r *Routes) HandleMatchSubmission(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	// Check content type
+	if !strings.Contains(req.Header.Get("Content-Type"), "application/json") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Content-Type must be application/json",
+		})
+		return
+	}
+
+	match, _, ok := r.endedMatch(w, req)
+	if !ok {
+		return
+	}
+	userContext, _ := middleware.GetUserFromContext(req)
+
+	if time.Since(*match.EndedAt) > postMatchWindow {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Submissions for this match are closed",
+		})
+		return
+	}
+	if _, err := database.LatestAcceptedSubmission(r.Db, match.ID, userContext.UserID); err == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "You already got accepted in this match",
+		})
+		return
+	}
+
+	// Limit request body size (1MB)
+	req.Body = http.MaxBytesReader(w, req.Body, 1048576)
+
+	var submissionReq SubmissionRequest
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&submissionReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if strings.TrimSpace(submissionReq.Code) == "" || len(submissionReq.Code) > 50000 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Code must be between 1 and 50,000 characters",
+		})
+		return
+	}
+
+	var problem *modles.ProblemPropaty
+	var err error
+	if r.Db.Cache != nil {
+		problem, err = r.Db.Cache.GetProblemById(match.ProblemID)
+	} else {
+		var p modles.ProblemPropaty
+		err = r.Db.Db.Preload("TestCases").Where("id = ?", match.ProblemID).First(&p).Error
+		problem = &p
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Problem not found",
+		})
+		return
+	}
+
+	sub := &modles.MatchSubmission{
+		MatchID:   match.ID,
+		ProblemID: match.ProblemID,
+		UserID:    userContext.UserID,
+		Language:  submissionReq.Language,
+		Code:      submissionReq.Code,
+	}
+	if err := database.SaveMatchSubmission(r.Db, sub); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to save submission",
+		})
+		return
+	}
+
+	// Judged like a classic match submission, clients poll /submissions/{id}
+	submissionID, err := r.JudgeQueue.Submit(cppruner.JudgeJob{
+		ProblemID:  match.ProblemID,
+		UserID:     userContext.UserID,
+		Language:   submissionReq.Language,
+		Code:       submissionReq.Code,
+		TestCases:  cppruner.HiddenTestCases(problem),
+		StopPolicy: cppruner.StopOnFirstFailure,
+	}, func(state cppruner.SubmissionState) {
+		if state.Finished() && state.Result != nil {
+			if err := database.UpdateMatchSubmissionResult(r.Db, sub.ID, string(state.Result.Verdict), state.Result.Score); err != nil {
+				fmt.Println(err)
+			}
+		}
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to queue submission: " + err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Submission queued",
+		Data: map[string]interface{}{
+			"submission_id": submissionID,
+			"status":        cppruner.StatusQueued,
+			"match_id":      match.ID,
+		},
+	})
+}
+
+// HandleOpponentCode - GET /matches/{id}/opponent (Protected route)
+// Shows the opponent's accepted code to a player who got accepted in the same match
+func (r *Routes) HandleOpponentCode(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	match, target, ok := r.hackTarget(w, req)
+	if !ok {
+		return
+	}
+
+	hacked, err := database.SubmissionHacked(r.Db, target.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch hacks",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Opponent code retrieved successfully",
+		Data: map[string]interface{}{
+			"match_id":      match.ID,
+			"submission_id": target.ID,
+			"user_id":       target.UserID,
+			"language":      target.Language,
+			"code":          target.Code,
+			"hacked":        hacked,
+		},
+	})
+}
+
+// HandleHack - POST /matches/{id}/hacks (Protected route)
+// Runs an input against the opponent's accepted code, the hacker scores when it breaks the code
+func (r *Routes) HandleHack(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	// Check content type
+	if !strings.Contains(req.Header.Get("Content-Type"), "application/json") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Content-Type must be application/json",
+		})
+		return
+	}
+
+	match, target, ok := r.hackTarget(w, req)
+	if !ok {
+		return
+	}
+	userContext, _ := middleware.GetUserFromContext(req)
+
+	// Limit request body size (1MB)
+	req.Body = http.MaxBytesReader(w, req.Body, 1048576)
+
+	var hackReq HackRequest
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&hackReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if strings.TrimSpace(hackReq.Input) == "" || len(hackReq.Input) > cppruner.MaxRunInputBytes {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: fmt.Sprintf("Input must be between 1 and %d bytes", cppruner.MaxRunInputBytes),
+		})
+		return
+	}
+
+	hacked, err := database.SubmissionHacked(r.Db, target.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch hacks",
+		})
+		return
+	}
+	if hacked {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "This solution was already hacked",
+		})
+		return
+	}
+
+	if !r.HackLimit.Allow(userContext.UserID) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Too many hacks, please wait a moment",
+		})
+		return
+	}
+
+	// The validator, reference and defending code all run on the judge, never on the API server
+	outcome, err := cppruner.HackOn(r.Judge, target.UserID, match.ProblemID, target.Language, target.Code, hackReq.Input)
+	if err != nil {
+		fmt.Printf("Hack on submission %d failed: %v\n", target.ID, err)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to judge hack: " + err.Error(),
+		})
+		return
+	}
+
+	hack := modles.Hack{
+		MatchID:        match.ID,
+		ProblemID:      match.ProblemID,
+		HackerID:       userContext.UserID,
+		DefenderID:     target.UserID,
+		SubmissionID:   target.ID,
+		Input:          hackReq.Input,
+		ExpectedOutput: outcome.ExpectedOutput,
+		Outcome:        outcome.Outcome,
+		Verdict:        string(outcome.Verdict),
+		Message:        outcome.Message,
+	}
+	switch outcome.Outcome {
+	case modles.HackSuccess:
+		hack.Points = hackSuccessPoints
+	case modles.HackFailed:
+		hack.Points = hackFailedPoints
+	}
+
+	if err := database.SaveHack(r.Db, &hack); err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	message := "Hack failed, the solution passed your input"
+	switch outcome.Outcome {
+	case modles.HackSuccess:
+		message = fmt.Sprintf("Hack successful! The solution got %s", outcome.Verdict)
+	case modles.HackInvalid:
+		message = "Invalid input: " + outcome.Message
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"hack":            hack,
+			"checker_message": outcome.CheckerMessage,
+		},
+	})
+}
+
+// HandleListHacks - GET /admin/hacks?outcome=success&page=1 (Admin route)
+// Lists hacks, newest first, successful ones by default since those are worth promoting
+func (r *Routes) HandleListHacks(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	const pageSize = 50
+
+	outcome := req.URL.Query().Get("outcome")
+	if outcome == "" {
+		outcome = modles.HackSuccess
+	} else if outcome == "all" {
+		outcome = ""
+	}
+
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	hacks, total, err := database.ListHacks(r.Db, outcome, pageSize, (page-1)*pageSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch hacks",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Hacks retrieved successfully",
+		Data: map[string]interface{}{
+			"hacks": hacks,
+			"total": total,
+			"page":  page,
+		},
+	})
+}
+
+// HandlePromoteHack - POST /admin/hacks/{id}/promote (Admin route)
+// Adds a successful hack to the problem's hidden test cases
+func (r *Routes) HandlePromoteHack(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	id, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid hack ID format",
+		})
+		return
+	}
+
+	var promoteReq PromoteRequest
+	req.Body = http.MaxBytesReader(w, req.Body, 4096)
+	if err := json.NewDecoder(req.Body).Decode(&promoteReq); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	testCase, err := database.PromoteHack(r.Db, uint(id), promoteReq.Group)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Hack added as a test case",
+		Data:    testCase,
+	})
+}
+
+// hackTarget checks that the match named by the {id} route variable can be hacked by the caller,
+// who must have got accepted in it (during the match or after it), and returns the opponent's
+// last accepted submission, writing the error response itself
+func (r *Routes) hackTarget(w http.ResponseWriter, req *http.Request) (*modles.Match, *modles.MatchSubmission, bool) {
+	match, opponent, ok := r.endedMatch(w, req)
+	if !ok {
+		return nil, nil, false
+	}
+	userContext, _ := middleware.GetUserFromContext(req)
+
+	// Only players who solved the problem themselves get to see and hack other code
+	if _, err := database.LatestAcceptedSubmission(r.Db, match.ID, userContext.UserID); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Get accepted in this match to unlock hacking",
+		})
+		return nil, nil, false
+	}
+
+	target, err := database.LatestAcceptedSubmission(r.Db, match.ID, opponent)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Your opponent has no accepted solution to hack",
+		})
+		return nil, nil, false
+	}
+	return match, target, true
+}
+
+// endedMatch checks that the match named by the {id} route variable ended, wasn't reverted and
+// that the caller played it, and returns it with the opponent's id, writing the error response itself
+func (r *Routes) endedMatch(w http.ResponseWriter, req *http.Request) (*modles.Match, uint, bool) {
+	userContext, ok := middleware.GetUserFromContext(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return nil, 0, false
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid match ID format",
+		})
+		return nil, 0, false
+	}
+
+	match, err := database.GetMatch(r.Db, uint(id))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Match not found",
+		})
+		return nil, 0, false
+	}
+
+	opponent := match.Player2ID
+	if match.Player2ID == userContext.UserID {
+		opponent = match.Player1ID
+	} else if match.Player1ID != userContext.UserID {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "You did not play this match",
+		})
+		return nil, 0, false
+	}
+
+	if match.Reverted {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Match was reverted",
+		})
+		return nil, 0, false
+	}
+
+	// Code stays hidden while the opponent can still use what it shows
+	if match.EndedAt == nil || match.Result == "" {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Match is still in progress",
+		})
+		return nil, 0, false
+	}
+	return match, opponent, true
+}
@@ -0,0 +1,222 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/iAmImran007/Code_War/pkg/auth"
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/database/dbtest"
+	"github.com/iAmImran007/Code_War/pkg/game"
+	"github.com/iAmImran007/Code_War/pkg/middleware"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+// Solutions the scripted judge knows: both pass the tests, only the int one overflows
+const (
+	intSolution  = "int add(int a, int b) { return a + b; }"
+	longSolution = "long long add(long long a, long long b) { return a + b; }"
+	overflowHack = "2000000000 2000000000"
+)
+
+// scriptedJudge accepts code that adds and breaks int code on overflowHack, standing in for the sandbox
+type scriptedJudge struct{}
+
+func (scriptedJudge) Judge(job cppruner.JudgeJob, progress func(status cppruner.SubmissionStatus, test int)) (cppruner.JudgeResult, error) {
+	if job.Hack {
+		outcome := cppruner.HackOutcome{Outcome: modles.HackFailed, Verdict: cppruner.VerdictAccepted, ExpectedOutput: "4000000000"}
+		if job.HackInput == overflowHack && !strings.Contains(job.Code, "long long") {
+			outcome.Outcome, outcome.Verdict = modles.HackSuccess, cppruner.VerdictWrongAnswer
+		}
+		return cppruner.JudgeResult{Verdict: outcome.Verdict, Hack: &outcome}, nil
+	}
+
+	total := len(job.TestCases)
+	if !strings.Contains(job.Code, "return a + b") {
+		return cppruner.JudgeResult{Verdict: cppruner.VerdictWrongAnswer, Total: total, FailedCases: []int{1}}, nil
+	}
+	return cppruner.JudgeResult{Verdict: cppruner.VerdictAccepted, Passed: total, Total: total, Score: cppruner.MaxScore}, nil
+}
+
+// testServer serves the routes on a test database with the scripted judge
+func testServer(t *testing.T) (*httptest.Server, *database.Databse) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := dbtest.New(t)
+
+	judge := scriptedJudge{}
+	queue := cppruner.NewJudgeQueue(db, 2, judge)
+	queue.Start()
+	runLimit := middleware.NewRateLimiter(db, "run", 10, time.Minute)
+
+	r := &Routes{
+		Router:         mux.NewRouter(),
+		Db:             db,
+		AuthMiddleware: middleware.NewAuthMiddleware(db),
+		GameRoom:       game.NewRoom(db, queue, runLimit, judge),
+		GameLimit:      game.NewGameLimitService(db),
+		JudgeQueue:     queue,
+		RunLimit:       runLimit,
+		HackLimit:      middleware.NewRateLimiter(db, "hack", 5, time.Minute),
+		Judge:          judge,
+	}
+	r.setupRoutes()
+
+	server := httptest.NewServer(r.Router)
+	t.Cleanup(server.Close)
+	return server, db
+}
+
+// testClient is a signed in user talking to the test server
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+	userID uint
+	cookie *http.Cookie
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, db *database.Databse, email string) *testClient {
+	user := modles.User{Email: email, Password: "x", Rating: 1500}
+	if err := db.Db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	tokens, err := auth.GanaretTokenPair(user.ID, user.Email, user.Role)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return &testClient{t: t, server: server, userID: user.ID, cookie: &http.Cookie{Name: "access_token", Value: tokens.AccessToken}}
+}
+
+// do sends body (nil for none) as JSON and decodes the response
+func (c *testClient) do(method, path string, body interface{}) (int, Response) {
+	c.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req, _ := http.NewRequest(method, c.server.URL+path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(c.cookie)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	var decoded Response
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+func (c *testClient) play() *websocket.Conn {
+	c.t.Helper()
+	header := http.Header{"Cookie": {c.cookie.String()}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(c.server.URL, "http")+"/ws", header)
+	if err != nil {
+		c.t.Fatalf("failed to join a game: %v", err)
+	}
+	c.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// await reads game messages until one of type msgType arrives
+func await(t *testing.T, conn *websocket.Conn, msgType string) game.Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var msg game.Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("no %s message: %v", msgType, err)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func TestHackAfterPlayedMatch(t *testing.T) {
+	server, db := testServer(t)
+
+	problem := modles.ProblemPropaty{Title: "Add", Difficulty: "easy", TestCases: []modles.TestCaesPropaty{{Input: "1 2", ExpectedOutput: "3"}}}
+	if err := db.Db.Create(&problem).Error; err != nil {
+		t.Fatalf("failed to create problem: %v", err)
+	}
+	winner := newTestClient(t, server, db, "winner@example.com")
+	loser := newTestClient(t, server, db, "loser@example.com")
+
+	// Play a classic match: the winner solves it with int code, the loser doesn't get there
+	winnerConn, loserConn := winner.play(), loser.play()
+	await(t, winnerConn, "problem")
+	await(t, loserConn, "problem")
+	winnerConn.WriteJSON(game.SubmissionMessage{Type: "submit", Language: "cpp", Code: intSolution})
+	if msg := await(t, winnerConn, "game_end"); msg.Status != "win" {
+		t.Fatalf("winner got %q", msg.Status)
+	}
+	if msg := await(t, loserConn, "game_end"); msg.Status != "lose" {
+		t.Fatalf("loser got %q", msg.Status)
+	}
+
+	var match modles.Match
+	if err := db.Db.First(&match).Error; err != nil || match.WinnerID != winner.userID {
+		t.Fatalf("match = %+v, %v, want won by user %d", match, err, winner.userID)
+	}
+	matchPath := fmt.Sprintf("/matches/%d", match.ID)
+
+	// Without an accepted solution of their own the loser can't hack yet
+	if status, _ := loser.do("POST", matchPath+"/hacks", HackRequest{Input: overflowHack}); status != http.StatusForbidden {
+		t.Fatalf("hack before getting accepted = %d, want %d", status, http.StatusForbidden)
+	}
+
+	// The match is over but the loser may still get accepted to unlock hacking
+	status, resp := loser.do("POST", matchPath+"/submissions", SubmissionRequest{Language: "cpp", Code: longSolution})
+	if status != http.StatusAccepted {
+		t.Fatalf("post-match submission = %d %q, want %d", status, resp.Message, http.StatusAccepted)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := database.LatestAcceptedSubmission(db, match.ID, loser.userID); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("post-match submission was never accepted")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status, _ := loser.do("POST", matchPath+"/submissions", SubmissionRequest{Language: "cpp", Code: longSolution}); status != http.StatusConflict {
+		t.Errorf("second post-match submission = %d, want %d", status, http.StatusConflict)
+	}
+
+	if status, resp := loser.do("GET", matchPath+"/opponent", nil); status != http.StatusOK {
+		t.Fatalf("opponent code = %d %q, want %d", status, resp.Message, http.StatusOK)
+	}
+
+	status, resp = loser.do("POST", matchPath+"/hacks", HackRequest{Input: overflowHack})
+	if status != http.StatusOK || !resp.Success {
+		t.Fatalf("hack = %d %q, want %d", status, resp.Message, http.StatusOK)
+	}
+	var hack modles.Hack
+	if err := db.Db.First(&hack).Error; err != nil {
+		t.Fatalf("hack was not stored: %v", err)
+	}
+	if hack.Outcome != modles.HackSuccess || hack.HackerID != loser.userID || hack.DefenderID != winner.userID || hack.Points != hackSuccessPoints {
+		t.Errorf("hack = %+v, want a successful hack of user %d by user %d", hack, winner.userID, loser.userID)
+	}
+
+	// The winner's int code was hacked, the loser's long long code holds
+	if status, _ := winner.do("POST", matchPath+"/hacks", HackRequest{Input: overflowHack}); status != http.StatusOK {
+		t.Fatalf("winner's hack = %d, want %d", status, http.StatusOK)
+	}
+	var last modles.Hack
+	db.Db.Order("id DESC").First(&last)
+	if last.Outcome != modles.HackFailed {
+		t.Errorf("hack of the long long solution = %s, want %s", last.Outcome, modles.HackFailed)
+	}
+}
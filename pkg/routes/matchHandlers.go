@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/middleware"
+)
+
+// TimelineEvent is one step of a match: "start", "submission", "end" or "hack"
+type TimelineEvent struct {
+	At     time.Time `json:"at"`
+	Type   string    `json:"type"`
+	UserID uint      `json:"user_id,omitempty"`
+
+	// Submissions, the code is only shown to the player who wrote it
+	SubmissionID uint    `json:"submission_id,omitempty"`
+	Language     string  `json:"language,omitempty"`
+	Code         string  `json:"code,omitempty"`
+	Verdict      string  `json:"verdict,omitempty"`
+	Score        float64 `json:"score,omitempty"`
+
+	// The end of the match
+	WinnerID  uint   `json:"winner_id,omitempty"`
+	Result    string `json:"result,omitempty"`
+	EndReason string `json:"end_reason,omitempty"`
+
+	// Hacks, UserID is the hacker
+	HackID  uint   `json:"hack_id,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// HandleListMatches - GET /matches?page=1 (Protected route)
+// Lists the matches the current user played, newest first
+func (r *Routes) HandleListMatches(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	const pageSize = 20
+
+	userContext, ok := middleware.GetUserFromContext(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	matches, total, err := database.ListUserMatches(r.Db, userContext.UserID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch matches",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Matches retrieved successfully",
+		Data: map[string]interface{}{
+			"matches": matches,
+			"total":   total,
+			"page":    page,
+		},
+	})
+}
+
+// HandleGetMatch - GET /matches/{id} (Protected route)
+// Shows one of the current user's matches with its timeline: start, submissions, end and hacks
+func (r *Routes) HandleGetMatch(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	userContext, ok := middleware.GetUserFromContext(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid match ID format",
+		})
+		return
+	}
+
+	match, err := database.GetMatch(r.Db, uint(id))
+	if err != nil || (match.Player1ID != userContext.UserID && match.Player2ID != userContext.UserID) {
+		// Other players' matches don't exist as far as the caller is concerned
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Match not found",
+		})
+		return
+	}
+
+	subs, err := database.ListMatchSubmissions(r.Db, match.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch submissions",
+		})
+		return
+	}
+	hacks, err := database.ListMatchHacks(r.Db, match.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch hacks",
+		})
+		return
+	}
+
+	started := match.StartedAt
+	if started.IsZero() {
+		started = match.CreatedAt
+	}
+	timeline := []TimelineEvent{{At: started, Type: "start"}}
+	for _, sub := range subs {
+		event := TimelineEvent{
+			At:           sub.CreatedAt,
+			Type:         "submission",
+			UserID:       sub.UserID,
+			SubmissionID: sub.ID,
+			Language:     sub.Language,
+			Verdict:      sub.Verdict,
+			Score:        sub.Score,
+		}
+		if sub.UserID == userContext.UserID {
+			event.Code = sub.Code
+		}
+		timeline = append(timeline, event)
+	}
+	if match.EndedAt != nil {
+		timeline = append(timeline, TimelineEvent{
+			At:        *match.EndedAt,
+			Type:      "end",
+			WinnerID:  match.WinnerID,
+			Result:    match.Result,
+			EndReason: match.EndReason,
+		})
+	}
+	for _, hack := range hacks {
+		timeline = append(timeline, TimelineEvent{
+			At:           hack.CreatedAt,
+			Type:         "hack",
+			UserID:       hack.HackerID,
+			SubmissionID: hack.SubmissionID,
+			Verdict:      hack.Verdict,
+			HackID:       hack.ID,
+			Outcome:      hack.Outcome,
+		})
+	}
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].At.Before(timeline[j].At) })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Match retrieved successfully",
+		Data: map[string]interface{}{
+			"match":    match,
+			"timeline": timeline,
+		},
+	})
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/iAmImran007/Code_War/pkg/database"
+)
+
+// HandleRatingHistory - GET /profile/{id}/ratings?page=1 (Protected route)
+// Lists a player's rating changes match by match, newest first
+func (r *Routes) HandleRatingHistory(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	const pageSize = 50
+
+	userID, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid user ID format",
+		})
+		return
+	}
+
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	history, total, err := database.ListRatingHistory(r.Db, uint(userID), pageSize, (page-1)*pageSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch rating history",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Rating history retrieved successfully",
+		Data: map[string]interface{}{
+			"history": history,
+			"total":   total,
+			"page":    page,
+		},
+	})
+}
+
+// HandleRecomputeRatings - POST /admin/ratings/recompute (Admin route)
+// Rebuilds every rating from the stored match results, e.g. after the rating formula changed
+func (r *Routes) HandleRecomputeRatings(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	replayed, err := database.RecomputeRatings(r.Db)
+	if err != nil {
+		fmt.Printf("Rating recompute failed: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to recompute ratings",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: fmt.Sprintf("Ratings recomputed from %d matches", replayed),
+		Data: map[string]interface{}{
+			"matches": replayed,
+		},
+	})
+}
@@ -0,0 +1,271 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/middleware"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+	"github.com/iAmImran007/Code_War/pkg/similarity"
+)
+
+// ReviewSide is one half of the side-by-side view of a similarity flag
+type ReviewSide struct {
+	SubmissionID uint   `json:"submission_id"`
+	UserID       uint   `json:"user_id"`
+	Language     string `json:"language"`
+	Code         string `json:"code"`
+	// MatchingLines are the lines sharing fingerprints with the other side
+	MatchingLines []int `json:"matching_lines"`
+}
+
+// HandleListFlags - GET /admin/flags?status=pending&page=1 (Admin route)
+// Lists the similarity review queue, highest score first
+func (r *Routes) HandleListFlags(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	const pageSize = 50
+
+	status := req.URL.Query().Get("status")
+	if status == "" {
+		status = modles.FlagPending
+	} else if status == "all" {
+		status = ""
+	}
+
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	flags, total, err := database.ListSimilarityFlags(r.Db, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to fetch flags",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Flags retrieved successfully",
+		Data: map[string]interface{}{
+			"flags": flags,
+			"total": total,
+			"page":  page,
+		},
+	})
+}
+
+// HandleGetFlag - GET /admin/flags/{id} (Admin route)
+// Shows a flagged submission next to the code it matched, with the shared lines marked
+func (r *Routes) HandleGetFlag(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	flag, ok := r.flagFromRequest(w, req)
+	if !ok {
+		return
+	}
+
+	var sub modles.MatchSubmission
+	if err := r.Db.Db.Where("id = ?", flag.SubmissionID).First(&sub).Error; err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Flagged submission not found",
+		})
+		return
+	}
+
+	left := ReviewSide{SubmissionID: sub.ID, UserID: sub.UserID, Language: sub.Language, Code: sub.Code}
+	var right ReviewSide
+
+	if flag.OtherSubmissionID != 0 {
+		var other modles.MatchSubmission
+		if err := r.Db.Db.Where("id = ?", flag.OtherSubmissionID).First(&other).Error; err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(Response{
+				Success: false,
+				Message: "Matched submission not found",
+			})
+			return
+		}
+		right = ReviewSide{SubmissionID: other.ID, UserID: other.UserID, Language: other.Language, Code: other.Code}
+	} else {
+		// Matched the reference solution
+		var problem modles.ProblemPropaty
+		if err := r.Db.Db.Select("id", "reference_language", "reference_source").Where("id = ?", flag.ProblemID).First(&problem).Error; err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(Response{
+				Success: false,
+				Message: "Problem not found",
+			})
+			return
+		}
+		right = ReviewSide{Language: problem.ReferenceLanguage, Code: problem.ReferenceSource}
+	}
+
+	left.MatchingLines, right.MatchingLines = similarity.MatchingLines(
+		similarity.Fingerprint(similarity.Normalize(left.Language, left.Code)),
+		similarity.Fingerprint(similarity.Normalize(right.Language, right.Code)),
+	)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Flag retrieved successfully",
+		Data: map[string]interface{}{
+			"flag":  flag,
+			"left":  left,
+			"right": right,
+		},
+	})
+}
+
+// HandleDismissFlag - POST /admin/flags/{id}/dismiss (Admin route)
+// Closes a flag without touching the match
+func (r *Routes) HandleDismissFlag(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	flag, ok := r.flagFromRequest(w, req)
+	if !ok {
+		return
+	}
+
+	userContext, _ := middleware.GetUserFromContext(req)
+	if err := database.ResolveSimilarityFlag(r.Db, flag.ID, modles.FlagDismissed, userContext.UserID); err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Flag dismissed",
+	})
+}
+
+// HandleRevertFlag - POST /admin/flags/{id}/revert (Admin route)
+// Confirms a flag and reverts the match it came from, rating changes included, other pending
+// flags of the match are closed along with it
+func (r *Routes) HandleRevertFlag(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	flag, ok := r.flagFromRequest(w, req)
+	if !ok {
+		return
+	}
+	if flag.Status != modles.FlagPending {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Flag is already " + flag.Status,
+		})
+		return
+	}
+
+	userContext, _ := middleware.GetUserFromContext(req)
+	match, err := database.RevertSimilarityFlag(r.Db, flag.ID, userContext.UserID)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Match reverted",
+		Data:    match,
+	})
+}
+
+// HandleRevertMatch - POST /admin/matches/{id}/revert (Admin route)
+// Reverts a match result and the rating changes it applied
+func (r *Routes) HandleRevertMatch(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	id, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid match ID format",
+		})
+		return
+	}
+
+	match, err := database.RevertMatch(r.Db, uint(id))
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Match reverted",
+		Data:    match,
+	})
+}
+
+// flagFromRequest loads the flag named by the {id} route variable, writing the error response itself
+func (r *Routes) flagFromRequest(w http.ResponseWriter, req *http.Request) (*modles.SimilarityFlag, bool) {
+	id, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid flag ID format",
+		})
+		return nil, false
+	}
+
+	flag, err := database.GetSimilarityFlag(r.Db, uint(id))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Flag not found",
+		})
+		return nil, false
+	}
+	return flag, true
+}
@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gorilla/mux"
 
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
 	"github.com/iAmImran007/Code_War/pkg/database"
 	"github.com/iAmImran007/Code_War/pkg/game"
 	"github.com/iAmImran007/Code_War/pkg/middleware"
@@ -16,16 +19,34 @@ type Routes struct {
 	GameRoom       *game.Room
 	StripieService *payment.StripeService
 	GameLimit      *game.GameLimitService
+	JudgeQueue     *cppruner.JudgeQueue
+	RunLimit       *middleware.RateLimiter
+	HackLimit      *middleware.RateLimiter
+	Judge          cppruner.JudgeBackend
 }
 
-func NewRouter(db *database.Databse) *Routes {
+// NewRouter wires every handler to one judge backend, cppruner.DefaultJudge over sandbox.
+// Submissions, runs, hacks and the admin tools all go to the judge nodes when JUDGE_NODES is set,
+// sandbox is only used in-process without them.
+func NewRouter(db *database.Databse, sandbox cppruner.SandboxBackend) *Routes {
+	judge := cppruner.DefaultJudge(sandbox, db)
+	judgeQueue := cppruner.NewJudgeQueue(db, cppruner.QueueWorkersFromEnv(), judge)
+	judgeQueue.Start()
+
+	// Custom runs are counted apart from submissions, shared by /run and the match socket
+	runLimit := middleware.NewRateLimiter(db, "run", middleware.RunRateLimitFromEnv(), time.Minute)
+
 	r := &Routes{
 		Router:         mux.NewRouter(),
 		Db:             db,
 		AuthMiddleware: middleware.NewAuthMiddleware(db),
-		GameRoom:       game.NewRoom(db),
+		GameRoom:       game.NewRoom(db, judgeQueue, runLimit, judge),
 		StripieService: payment.NewStripeService(db),
 		GameLimit:      game.NewGameLimitService(db),
+		JudgeQueue:     judgeQueue,
+		RunLimit:       runLimit,
+		HackLimit:      middleware.NewRateLimiter(db, "hack", 5, time.Minute),
+		Judge:          judge,
 	}
 
 	r.setupRoutes()
@@ -52,10 +73,31 @@ func (r *Routes) setupRoutes() {
 	// Protected routes
 	r.Router.HandleFunc("/logout", r.AuthMiddleware.RequireAuth(r.handleLogout)).Methods("POST")
 	r.Router.HandleFunc("/profile/{id}", r.AuthMiddleware.RequireAuth(r.handleProfile)).Methods("GET")
+	r.Router.HandleFunc("/profile/{id}/ratings", r.AuthMiddleware.RequireAuth(r.HandleRatingHistory)).Methods("GET")
 	//r.Router.HandleFunc("/ws", r.AuthMiddleware.RequireAuth(r.GameRoom.HandleWs))
 	r.Router.HandleFunc("/ws", r.AuthMiddleware.RequireAuth(r.handleGameWithLimit))
 	r.Router.HandleFunc("/problem/{id}", r.AuthMiddleware.RequireAuth(r.GetProblemById)).Methods("GET")
 	r.Router.HandleFunc("/submit/{id}", r.AuthMiddleware.RequireAuth(r.HandleSubmition)).Methods("POST")
+	r.Router.HandleFunc("/submissions/{id}", r.AuthMiddleware.RequireAuth(r.HandleSubmissionStatus)).Methods("GET")
+	r.Router.HandleFunc("/run/{id}", r.AuthMiddleware.RequireAuth(r.HandleRun)).Methods("POST")
+	r.Router.HandleFunc("/matches", r.AuthMiddleware.RequireAuth(r.HandleListMatches)).Methods("GET")
+	r.Router.HandleFunc("/matches/{id}", r.AuthMiddleware.RequireAuth(r.HandleGetMatch)).Methods("GET")
+	r.Router.HandleFunc("/matches/{id}/submissions", r.AuthMiddleware.RequireAuth(r.HandleMatchSubmission)).Methods("POST")
+	r.Router.HandleFunc("/matches/{id}/opponent", r.AuthMiddleware.RequireAuth(r.HandleOpponentCode)).Methods("GET")
+	r.Router.HandleFunc("/matches/{id}/hacks", r.AuthMiddleware.RequireAuth(r.HandleHack)).Methods("POST")
+
+	// Admin routes
+	r.Router.HandleFunc("/admin/stress/{id}", r.AuthMiddleware.RequireAdmin(r.HandleStressTest)).Methods("POST")
+	r.Router.HandleFunc("/admin/counterexamples/{id}/promote", r.AuthMiddleware.RequireAdmin(r.HandlePromoteCounterExample)).Methods("POST")
+	r.Router.HandleFunc("/admin/problems/{id}/reference", r.AuthMiddleware.RequireAdmin(r.HandleUpdateReference)).Methods("PUT")
+	r.Router.HandleFunc("/admin/flags", r.AuthMiddleware.RequireAdmin(r.HandleListFlags)).Methods("GET")
+	r.Router.HandleFunc("/admin/flags/{id}", r.AuthMiddleware.RequireAdmin(r.HandleGetFlag)).Methods("GET")
+	r.Router.HandleFunc("/admin/flags/{id}/dismiss", r.AuthMiddleware.RequireAdmin(r.HandleDismissFlag)).Methods("POST")
+	r.Router.HandleFunc("/admin/flags/{id}/revert", r.AuthMiddleware.RequireAdmin(r.HandleRevertFlag)).Methods("POST")
+	r.Router.HandleFunc("/admin/matches/{id}/revert", r.AuthMiddleware.RequireAdmin(r.HandleRevertMatch)).Methods("POST")
+	r.Router.HandleFunc("/admin/hacks", r.AuthMiddleware.RequireAdmin(r.HandleListHacks)).Methods("GET")
+	r.Router.HandleFunc("/admin/hacks/{id}/promote", r.AuthMiddleware.RequireAdmin(r.HandlePromoteHack)).Methods("POST")
+	r.Router.HandleFunc("/admin/ratings/recompute", r.AuthMiddleware.RequireAdmin(r.HandleRecomputeRatings)).Methods("POST")
 
 	//stripe routes
 	r.Router.HandleFunc("/create-checkout-session", r.AuthMiddleware.RequireAuth(r.StripieService.CreateCheckoutSession)).Methods("POST")
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/middleware"
+)
+
+type RunRequest struct {
+	Code     string `json:"code"`
+	Language string `json:"language"`
+	// Input is the stdin to run on, the problem's examples are used when it is left out
+	Input *string `json:"input,omitempty"`
+}
+
+// HandleRun - POST /run/{id} (Protected route)
+// Runs code on custom input or the examples without counting as a submission
+func (r *Routes) HandleRun(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	// Check content type
+	if !strings.Contains(req.Header.Get("Content-Type"), "application/json") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Content-Type must be application/json",
+		})
+		return
+	}
+
+	userContext, ok := middleware.GetUserFromContext(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	// Get problem ID from URL path
+	problemID, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid problem ID format",
+		})
+		return
+	}
+
+	// Limit request body size (1MB)
+	req.Body = http.MaxBytesReader(w, req.Body, 1048576)
+
+	var runReq RunRequest
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&runReq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if strings.TrimSpace(runReq.Code) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Code cannot be empty",
+		})
+		return
+	}
+
+	if len(runReq.Code) > 50000 { // 50KB limit
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Code is too long (maximum 50,000 characters)",
+		})
+		return
+	}
+
+	var inputs []string
+	if runReq.Input != nil {
+		if len(*runReq.Input) > cppruner.MaxRunInputBytes {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(Response{
+				Success: false,
+				Message: fmt.Sprintf("Input is too long (maximum %d bytes)", cppruner.MaxRunInputBytes),
+			})
+			return
+		}
+		inputs = []string{*runReq.Input}
+	}
+
+	if _, err := cppruner.GetLanguage(runReq.Language); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Unsupported language",
+			Data: map[string]interface{}{
+				"supported_languages": cppruner.SupportedLanguages(),
+			},
+		})
+		return
+	}
+
+	// Runs have their own budget so trying things out never eats into submissions
+	if !r.RunLimit.Allow(userContext.UserID) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Too many runs, please wait a moment",
+		})
+		return
+	}
+
+	result, err := r.Judge.Judge(cppruner.JudgeJob{
+		ProblemID: uint(problemID),
+		UserID:    userContext.UserID,
+		Language:  runReq.Language,
+		Code:      runReq.Code,
+		Run:       true,
+		Inputs:    inputs,
+	}, nil)
+	if err != nil && result.Verdict != cppruner.VerdictCompileError {
+		fmt.Printf("Run failed: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to run code",
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"problem_id": problemID,
+		"verdict":    result.Verdict,
+	}
+	message := "Run finished"
+	if compileErr, ok := err.(*cppruner.CompileError); ok {
+		data["compile_output"] = compileErr.Details()
+		data["compile_errors"] = compileErr.Diagnostics
+		message = "Compilation failed"
+	} else {
+		data["cases"] = result.Cases
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: message,
+		Data:    data,
+	})
+}
@@ -2,27 +2,31 @@ package routes
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
-	"github.com/iAmImran007/Code_War/pkg/auth"
 	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/middleware"
 	"github.com/iAmImran007/Code_War/pkg/modles"
 	"gorm.io/gorm"
 )
 
 type SubmissionRequest struct {
-	Code string `json:"code"`
+	Code     string `json:"code"`
+	Language string `json:"language"`
 }
 
 type ProblemResponse struct {
 	ID          uint             `json:"id"`
 	Title       string           `json:"title"`
 	Description string           `json:"description"`
-	FuncBody    string           `json:"func_body"`
-	Examples    []modles.Example `json:"examples"` // Include examples in the response
+	FuncBody    string            `json:"func_body"`
+	Stubs       map[string]string `json:"stubs"`    // Starter code for every supported language
+	Examples    []modles.Example  `json:"examples"` // Include examples in the response
+	Limits      cppruner.Limits   `json:"limits"`   // Before the per-language multipliers
 }
 
 type AllProblemsResponse struct {
@@ -141,7 +145,7 @@ func (r *Routes) GetProblemById(w http.ResponseWriter, req *http.Request) {
 	} else {
 		// Fallback to direct DB query
 		var p modles.ProblemPropaty
-		err = r.Db.Db.Preload("Examples").Where("id = ?", problemID).First(&p).Error
+		err = r.Db.Db.Preload("Examples").Preload("Templates").Where("id = ?", problemID).First(&p).Error
 		problem = &p
 	}
 
@@ -160,7 +164,9 @@ func (r *Routes) GetProblemById(w http.ResponseWriter, req *http.Request) {
 		Title:       problem.Title,
 		Description: problem.Description,
 		FuncBody:    problem.FuncBody, // Include only the function body
+		Stubs:       cppruner.StubsFor(problem),
 		Examples:    problem.Examples, // Include examples in the response
+		Limits:      cppruner.ProblemLimits(problem),
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -260,6 +266,19 @@ func (r *Routes) HandleSubmition(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Make sure we have a toolchain for the requested language
+	if _, err := cppruner.GetLanguage(submissionReq.Language); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Unsupported language",
+			Data: map[string]interface{}{
+				"supported_languages": cppruner.SupportedLanguages(),
+			},
+		})
+		return
+	}
+
 	// Try to get problem from cache first (if available)
 	var problem *modles.ProblemPropaty
 	if r.Db.Cache != nil {
@@ -281,82 +300,134 @@ func (r *Routes) HandleSubmition(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Convert TestCaesPropaty to TestCase for judge function
-	var testCases []cppruner.TestCase
-	for _, tc := range problem.TestCases {
-		testCases = append(testCases, cppruner.TestCase{
-			Input:          tc.Input,
-			ExpectedOutput: tc.ExpectedOutput,
+	testCases := cppruner.HiddenTestCases(problem)
+
+	userContext, _ := middleware.GetUserFromContext(req)
+	userID := userContext.UserID
+
+	// Queue the code for judging and answer right away, clients poll /submissions/{id}
+	// Practice runs every test so the feedback and the score are complete
+	submissionID, err := r.JudgeQueue.Submit(cppruner.JudgeJob{
+		ProblemID:  uint(problemID),
+		UserID:     userID,
+		Language:   submissionReq.Language,
+		Code:       submissionReq.Code,
+		TestCases:  testCases,
+		StopPolicy: cppruner.RunAll,
+	}, func(state cppruner.SubmissionState) {
+		if state.Result != nil && state.Result.Verdict != cppruner.VerdictCompileError && state.Result.Score >= cppruner.MaxScore {
+			r.incrementSolvedProblems(userID, uint(problemID))
+		}
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Failed to queue submission",
 		})
+		return
 	}
 
-	// Judge the code
-	result, err := cppruner.JudgeCode(uint(problemID), submissionReq.Code, testCases, r.Db)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Message: "Submission queued",
+		Data: map[string]interface{}{
+			"submission_id": submissionID,
+			"status":        cppruner.StatusQueued,
+			"problem_id":    problemID,
+		},
+	})
+}
+
+// HandleSubmissionStatus - GET /submissions/{id} (Protected route)
+func (r *Routes) HandleSubmissionStatus(w http.ResponseWriter, req *http.Request) {
+	// Set security headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+
+	submissionID := mux.Vars(req)["id"]
+	userContext, ok := middleware.GetUserFromContext(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(Response{
 			Success: false,
-			Message: "Compilation or runtime error",
-			Data: map[string]interface{}{
-				"error": err.Error(),
-			},
+			Message: "User not authenticated",
 		})
 		return
 	}
 
-	// Determine status based on results
-	status := "partial"
-	message := "Some test cases failed"
-
-	if result.Passed == result.Total {
-		status = "accepted"
-		message = "All test cases passed! Solution accepted."
-		r.incrementSolvedProblems(req, uint(problemID))
- 
-	} else if result.Passed == 0 {
-		status = "failed"
-		message = "All test cases failed"
+	// Unknown and foreign submissions look the same so IDs can't be probed
+	state, err := r.JudgeQueue.Status(submissionID)
+	if err != nil || state.UserID != userContext.UserID {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{
+			Success: false,
+			Message: "Submission not found",
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"submission_id": state.ID,
+		"problem_id":    state.ProblemID,
+		"language":      state.Language,
+		"status":        state.Status,
+		"current_test":  state.CurrentTest,
+		"total_tests":   state.TotalTests,
+	}
+	message := "Submission is being judged"
+
+	if state.Finished() {
+		if state.Error != "" {
+			data["error"] = state.Error
+		}
+		message = "Compilation or runtime error"
+		if result := state.Result; result != nil {
+			data["verdict"] = result.Verdict
+			if result.Verdict != cppruner.VerdictCompileError {
+				data["result"], message = submissionSummary(*result)
+				data["passed"] = result.Passed
+				data["total"] = result.Total
+				data["failed_cases"] = result.FailedCases
+				data["score"] = result.Score
+				data["subtasks"] = result.Subtasks
+				data["cases"] = result.Redacted().Cases
+			} else {
+				data["compile_errors"] = result.CompileErrors
+			}
+		}
 	}
 
-	// Success response with results
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(Response{
 		Success: true,
 		Message: message,
-		Data: map[string]interface{}{
-			"status":       status,
-			"passed":       result.Passed,
-			"total":        result.Total,
-			"failed_cases": result.FailedCases,
-			"problem_id":   problemID,
-		},
+		Data:    data,
 	})
 }
 
-
-func (r *Routes) incrementSolvedProblems(req *http.Request, problemID uint) {
-    // Get user ID from JWT token
-    accessCookie, err := req.Cookie("access_token")
-    if err != nil {
-        return // No token, skip
-    }
-    
-    if accessCookie.Value == "" {
-        return // Empty token, skip
-    }
-    
-    claims, err := auth.ValidateToken(accessCookie.Value)
-    if err != nil {
-        return // Invalid token, skip
-    }
-    
-    // Check if user already solved this problem (optional - to avoid duplicate counting)
-    var count int64
-    r.Db.Db.Table("user_problems").Where("user_id = ? AND problem_id = ?", claims.UserID, problemID).Count(&count)
-    
-    if count == 0 { 
-        // For now, just increment the count
-        r.Db.Db.Model(&modles.User{}).Where("id = ?", claims.UserID).
-            Update("solved_problems", gorm.Expr("solved_problems + ?", 1))
-    }
+// submissionSummary determines status and message based on the score
+func submissionSummary(result cppruner.JudgeResult) (string, string) {
+	if result.Score >= cppruner.MaxScore {
+		return "accepted", "All test cases passed! Solution accepted."
+	}
+	if result.Passed == 0 {
+		return "failed", "All test cases failed"
+	}
+	return "partial", fmt.Sprintf("Partially accepted, scored %g/%g", result.Score, cppruner.MaxScore)
 }
 
+func (r *Routes) incrementSolvedProblems(userID uint, problemID uint) {
+	// Check if user already solved this problem (optional - to avoid duplicate counting)
+	var count int64
+	r.Db.Db.Table("user_problems").Where("user_id = ? AND problem_id = ?", userID, problemID).Count(&count)
+
+	if count == 0 {
+		// For now, just increment the count
+		r.Db.Db.Model(&modles.User{}).Where("id = ?", userID).
+			Update("solved_problems", gorm.Expr("solved_problems + ?", 1))
+	}
+}
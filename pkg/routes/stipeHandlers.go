@@ -17,6 +17,12 @@ func (r *Routes) handleGameWithLimit(w http.ResponseWriter, req *http.Request) {
 	}
 	userID := userContext.UserID
 
+	// Reconnecting to a match in progress isn't a new game
+	if r.GameRoom.CanResume(userID) {
+		r.GameRoom.HandleWs(w, req)
+		return
+	}
+
 	// Check if user can play
 	canPlay, err := r.GameLimit.CanPlayGame(userID)
 	if err != nil {
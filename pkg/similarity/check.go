@@ -0,0 +1,92 @@
+package similarity
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	cppruner "github.com/iAmImran007/Code_War/pkg/cppRuner"
+	"github.com/iAmImran007/Code_War/pkg/database"
+	"github.com/iAmImran007/Code_War/pkg/modles"
+)
+
+const (
+	// DefaultThreshold is the score from which a submission is flagged
+	DefaultThreshold = 0.8
+
+	// MinFingerprints keeps short solutions out of the check, a few lines of code written
+	// independently look alike once identifiers are abstracted
+	MinFingerprints = 15
+
+	// maxCandidates bounds how many earlier submissions one check compares against
+	maxCandidates = 1000
+)
+
+// ThresholdFromEnv reads SIMILARITY_THRESHOLD, a score between 0 and 1
+func ThresholdFromEnv() float64 {
+	if t, err := strconv.ParseFloat(os.Getenv("SIMILARITY_THRESHOLD"), 64); err == nil && t > 0 && t <= 1 {
+		return t
+	}
+	return DefaultThreshold
+}
+
+// CheckSubmission fingerprints an accepted match submission, compares it with the accepted
+// submissions of other players on the same problem and with the problem's reference solution,
+// and flags it for review when one of them scores threshold or more. At most one flag is
+// raised per other player, for their closest submission.
+func CheckSubmission(db *database.Databse, sub *modles.MatchSubmission, problem *modles.ProblemPropaty, threshold float64) ([]modles.SimilarityFlag, error) {
+	hashes := Hashes(Fingerprint(Normalize(sub.Language, sub.Code)))
+	sub.Fingerprint = EncodeHashes(hashes)
+	if err := db.Db.Model(&modles.MatchSubmission{}).Where("id = ?", sub.ID).Update("fingerprint", sub.Fingerprint).Error; err != nil {
+		return nil, fmt.Errorf("failed to store fingerprint of submission %d: %v", sub.ID, err)
+	}
+	if len(hashes) < MinFingerprints {
+		return nil, nil
+	}
+
+	var candidates []modles.MatchSubmission
+	err := db.Db.Select("id", "match_id", "user_id", "fingerprint").
+		Where("problem_id = ? AND user_id <> ? AND fingerprint <> ''", sub.ProblemID, sub.UserID).
+		Order("id DESC").Limit(maxCandidates).Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch submissions of problem %d: %v", sub.ProblemID, err)
+	}
+
+	best := map[uint]modles.SimilarityFlag{}
+	for _, c := range candidates {
+		other := DecodeHashes(c.Fingerprint)
+		if len(other) < MinFingerprints {
+			continue
+		}
+		score := Score(hashes, other)
+		if score < threshold || score <= best[c.UserID].Score {
+			continue
+		}
+		best[c.UserID] = modles.SimilarityFlag{OtherSubmissionID: c.ID, OtherUserID: c.UserID, Score: score}
+	}
+
+	if problem != nil && problem.ReferenceSource != "" {
+		language := problem.ReferenceLanguage
+		if language == "" {
+			language = cppruner.DefaultLanguage
+		}
+		ref := Hashes(Fingerprint(Normalize(language, problem.ReferenceSource)))
+		if score := Score(hashes, ref); len(ref) >= MinFingerprints && score >= threshold {
+			best[0] = modles.SimilarityFlag{Score: score}
+		}
+	}
+
+	flags := make([]modles.SimilarityFlag, 0, len(best))
+	for _, f := range best {
+		f.ProblemID = sub.ProblemID
+		f.MatchID = sub.MatchID
+		f.SubmissionID = sub.ID
+		f.UserID = sub.UserID
+		f.Status = modles.FlagPending
+		flags = append(flags, f)
+	}
+	if err := database.SaveSimilarityFlags(db, flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
@@ -0,0 +1,142 @@
+package similarity
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// kgram tokens are hashed together, shorter runs than this are never counted as copied
+	kgram = 5
+	// window is the winnowing window, a copied run of window+kgram-1 tokens is always caught
+	window = 4
+)
+
+// Print is one selected k-gram hash and the source lines it spans
+type Print struct {
+	Hash      uint64
+	FirstLine int
+	LastLine  int
+}
+
+// Fingerprint picks the winnowed k-gram hashes of tokens (Schleimer, Wilkerson and Aiken):
+// the smallest hash of every window of consecutive k-grams, rightmost on ties, each position once
+func Fingerprint(tokens []Token) []Print {
+	if len(tokens) < kgram {
+		return nil
+	}
+
+	grams := make([]Print, len(tokens)-kgram+1)
+	for i := range grams {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+kgram] {
+			h.Write([]byte(t.Text))
+			h.Write([]byte{0})
+		}
+		grams[i] = Print{Hash: h.Sum64(), FirstLine: tokens[i].Line, LastLine: tokens[i+kgram-1].Line}
+	}
+
+	w := min(window, len(grams))
+	var prints []Print
+	last := -1
+	for start := 0; start+w <= len(grams); start++ {
+		pick := start
+		for j := start; j < start+w; j++ {
+			if grams[j].Hash <= grams[pick].Hash {
+				pick = j
+			}
+		}
+		if pick != last {
+			prints = append(prints, grams[pick])
+			last = pick
+		}
+	}
+	return prints
+}
+
+// Hashes is the set of hashes of prints, the form fingerprints are stored and compared in
+func Hashes(prints []Print) []uint64 {
+	seen := map[uint64]bool{}
+	var hashes []uint64
+	for _, p := range prints {
+		if !seen[p.Hash] {
+			seen[p.Hash] = true
+			hashes = append(hashes, p.Hash)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	return hashes
+}
+
+// Score is the share of the smaller fingerprint found in the other one, from 0 to 1.
+// Measuring against the smaller side catches a copied solution padded with extra code.
+func Score(a, b []uint64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inB := make(map[uint64]bool, len(b))
+	for _, h := range b {
+		inB[h] = true
+	}
+	shared := 0
+	for _, h := range a {
+		if inB[h] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(min(len(a), len(b)))
+}
+
+// MatchingLines lists the source lines of a and b covered by k-grams the two have in common,
+// what a side-by-side view highlights
+func MatchingLines(a, b []Print) ([]int, []int) {
+	inA := map[uint64]bool{}
+	for _, p := range a {
+		inA[p.Hash] = true
+	}
+	inB := map[uint64]bool{}
+	for _, p := range b {
+		inB[p.Hash] = true
+	}
+	return coveredLines(a, inB), coveredLines(b, inA)
+}
+
+func coveredLines(prints []Print, shared map[uint64]bool) []int {
+	covered := map[int]bool{}
+	for _, p := range prints {
+		if !shared[p.Hash] {
+			continue
+		}
+		for l := p.FirstLine; l <= p.LastLine; l++ {
+			covered[l] = true
+		}
+	}
+	lines := make([]int, 0, len(covered))
+	for l := range covered {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// EncodeHashes stores a fingerprint as space separated hex
+func EncodeHashes(hashes []uint64) string {
+	parts := make([]string, len(hashes))
+	for i, h := range hashes {
+		parts[i] = strconv.FormatUint(h, 16)
+	}
+	return strings.Join(parts, " ")
+}
+
+// DecodeHashes reads a fingerprint written by EncodeHashes, malformed entries are skipped
+func DecodeHashes(s string) []uint64 {
+	var hashes []uint64
+	for _, part := range strings.Fields(s) {
+		if h, err := strconv.ParseUint(part, 16, 64); err == nil {
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes
+}
@@ -0,0 +1,137 @@
+package similarity
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is one normalized token of a submission and the source line it came from
+type Token struct {
+	Text string
+	Line int
+}
+
+// Placeholders for abstracted tokens, renaming variables or changing constants keeps the stream the same
+const (
+	identToken  = "I"
+	numberToken = "N"
+	stringToken = "S"
+)
+
+// keywords stay as they are so the structure of the code survives abstraction, one set covers
+// every judge language since a keyword of one language is an identifier in another either way
+var keywords = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		auto bool break case catch char class const constexpr continue default delete do double else enum
+		extern false float for friend goto if inline int long namespace new nullptr operator private
+		protected public return short signed sizeof static struct switch template this throw true try
+		typedef typename union unsigned using virtual void volatile while
+		and as assert def del elif except finally from global import in is lambda nonlocal not or pass
+		raise with yield None True False
+		chan defer fallthrough func go interface map package range select type var
+		boolean byte extends final implements instanceof super throws
+		fn impl let loop match mut pub ref self Self trait use where
+		async await function of undefined
+	`) {
+		keywords[kw] = true
+	}
+}
+
+// Normalize turns code into its token stream: comments and whitespace dropped, identifiers,
+// numbers and string literals replaced by placeholders, keywords and operators kept.
+// Python-style # comments are only stripped for python3, in C and C++ # starts a directive.
+func Normalize(language, code string) []Token {
+	hashComments := language == "python3"
+
+	var tokens []Token
+	line := 1
+	src := []rune(code)
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '/', c == '#' && hashComments:
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			i += 2
+			for i < len(src) && !(src[i] == '*' && i+1 < len(src) && src[i+1] == '/') {
+				if src[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i += 2
+
+		case c == '"' || c == '\'' || c == '`':
+			start := line
+			i = skipString(src, i, &line)
+			tokens = append(tokens, Token{Text: stringToken, Line: start})
+
+		case unicode.IsDigit(c):
+			for i < len(src) && (unicode.IsLetter(src[i]) || unicode.IsDigit(src[i]) || src[i] == '.' || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, Token{Text: numberToken, Line: line})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(src[i]) || unicode.IsDigit(src[i]) || src[i] == '_') {
+				i++
+			}
+			word := string(src[start:i])
+			if !keywords[word] {
+				word = identToken
+			}
+			tokens = append(tokens, Token{Text: word, Line: line})
+
+		default:
+			tokens = append(tokens, Token{Text: string(c), Line: line})
+			i++
+		}
+	}
+	return tokens
+}
+
+// skipString returns the index after the string literal starting at i, python's triple quotes included
+func skipString(src []rune, i int, line *int) int {
+	quote := src[i]
+	triple := i+2 < len(src) && src[i+1] == quote && src[i+2] == quote
+	if triple {
+		i += 3
+	} else {
+		i++
+	}
+
+	for i < len(src) {
+		switch {
+		case src[i] == '\\':
+			i++
+		case src[i] == '\n':
+			// An unterminated single-line literal ends at the line break
+			if !triple && quote != '`' {
+				return i
+			}
+			*line++
+		case src[i] == quote:
+			if !triple {
+				return i + 1
+			}
+			if i+2 < len(src) && src[i+1] == quote && src[i+2] == quote {
+				return i + 3
+			}
+		}
+		i++
+	}
+	return i
+}
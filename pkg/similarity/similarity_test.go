@@ -0,0 +1,247 @@
+package similarity
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// texts is the token stream without the line numbers
+func texts(tokens []Token) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.Text
+	}
+	return out
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     string
+	}{
+		{"identifiers and numbers", "cpp", "int total = count + 42;", "int I = I + N ;"},
+		{"renamed identifiers", "cpp", "int s = n + 7;", "int I = I + N ;"},
+		{"whitespace", "cpp", "int\n\ttotal=count\n+42 ;", "int I = I + N ;"},
+		{"line comment", "cpp", "int total = count + 42; // the sum", "int I = I + N ;"},
+		{"block comment", "cpp", "int /* the sum */ total = count + 42;", "int I = I + N ;"},
+		{"strings", "cpp", `cout << "a \" b" << 'c';`, "I < < S < < S ;"},
+		{"directive", "cpp", "#include <vector>", "# I < I >"},
+		{"python comment", "python3", "x = 1  # one", "I = N"},
+		{"python triple quotes", "python3", `s = """a "b" c"""`, "I = S"},
+		{"keywords of other languages", "go", "for i := range xs { return }", "for I : = range I { return }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(texts(Normalize(tt.language, tt.code)), " ")
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLines(t *testing.T) {
+	code := "int a;\n/* two\nlines */\nstring s = \"x\";\n"
+	var lines []int
+	for _, tok := range Normalize("cpp", code) {
+		lines = append(lines, tok.Line)
+	}
+	want := []int{1, 1, 1, 4, 4, 4, 4, 4}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %v, want %v", lines, want)
+	}
+}
+
+// stream builds a token stream from space separated texts, one token per line
+func stream(s string) []Token {
+	var tokens []Token
+	for i, text := range strings.Fields(s) {
+		tokens = append(tokens, Token{Text: text, Line: i + 1})
+	}
+	return tokens
+}
+
+func TestFingerprint(t *testing.T) {
+	if prints := Fingerprint(stream("a b c d")); prints != nil {
+		t.Errorf("fingerprint of %d tokens = %v, want none", kgram-1, prints)
+	}
+	if prints := Fingerprint(stream("a b c d e")); len(prints) != 1 || prints[0].FirstLine != 1 || prints[0].LastLine != kgram {
+		t.Errorf("fingerprint of one k-gram = %+v, want it spanning lines 1-%d", prints, kgram)
+	}
+
+	// A run of window+kgram-1 shared tokens is always caught, whatever surrounds it
+	shared := "x1 x2 x3 x4 x5 x6 x7 x8"
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"at the start", shared + " a1 a2 a3 a4 a5 a6", shared + " b1 b2 b3 b4 b5 b6"},
+		{"in the middle", "a1 a2 a3 a4 a5 " + shared + " a6 a7 a8", "b1 b2 " + shared + " b3 b4 b5 b6 b7"},
+		{"at the end", "a1 a2 a3 a4 a5 a6 a7 " + shared, "b1 " + shared},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Fingerprint(stream(tt.a)), Fingerprint(stream(tt.b))
+			linesA, linesB := MatchingLines(a, b)
+			if len(linesA) == 0 || len(linesB) == 0 {
+				t.Errorf("shared run not caught: %v %v", linesA, linesB)
+			}
+		})
+	}
+
+	// Consecutive windows share their minimum, it is only picked once
+	prints := Fingerprint(stream(strings.Repeat("a b c d e f g ", 10)))
+	for i := 1; i < len(prints); i++ {
+		if prints[i].FirstLine <= prints[i-1].FirstLine {
+			t.Fatalf("prints out of order or repeated at %d: %+v", i, prints)
+		}
+	}
+}
+
+const sumSolution = `#include <bits/stdc++.h>
+using namespace std;
+
+int main() {
+    int n;
+    cin >> n;
+    vector<long long> a(n);
+    for (int i = 0; i < n; i++) cin >> a[i];
+    long long best = a[0], cur = 0;
+    for (int i = 0; i < n; i++) {
+        cur = max(a[i], cur + a[i]);
+        best = max(best, cur);
+    }
+    cout << best << endl;
+    return 0;
+}
+`
+
+// renamed and reformatted, the same solution as far as the check goes
+const sumRenamed = `#include <bits/stdc++.h>
+using namespace std;
+// kadane
+int main(){
+  int len; cin>>len;
+  vector<long long> v(len);
+  for(int j=0;j<len;j++) cin>>v[j];
+  long long answer=v[0], running=0;
+  for(int j=0;j<len;j++){ running=max(v[j],running+v[j]); answer=max(answer,running); }
+  cout<<answer<<endl;
+  return 0;
+}
+`
+
+// the same solution with unrelated code around it
+const sumPadded = `#include <bits/stdc++.h>
+using namespace std;
+
+struct Reader {
+    int next() { int x; cin >> x; return x; }
+};
+
+void unused(map<string, int>& seen, const string& key) {
+    if (seen.count(key)) seen[key]++;
+    else seen[key] = 1;
+}
+
+int main() {
+    int n;
+    cin >> n;
+    vector<long long> a(n);
+    for (int i = 0; i < n; i++) cin >> a[i];
+    long long best = a[0], cur = 0;
+    for (int i = 0; i < n; i++) {
+        cur = max(a[i], cur + a[i]);
+        best = max(best, cur);
+    }
+    cout << best << endl;
+    return 0;
+}
+`
+
+// an unrelated solution in the same language
+const sieveSolution = `#include <bits/stdc++.h>
+using namespace std;
+
+int main() {
+    int limit;
+    scanf("%d", &limit);
+    vector<bool> composite(limit + 1, false);
+    vector<int> primes;
+    for (int p = 2; p <= limit; ++p) {
+        if (composite[p]) continue;
+        primes.push_back(p);
+        for (long long q = 1LL * p * p; q <= limit; q += p)
+            composite[q] = true;
+    }
+    printf("%zu\n", primes.size());
+    for (size_t k = 0; k < primes.size(); ++k)
+        printf(k + 1 == primes.size() ? "%d\n" : "%d ", primes[k]);
+}
+`
+
+// an unrelated solution in another language
+const pythonSolution = `import sys
+
+def solve():
+    data = sys.stdin.read().split()
+    q = int(data[0])
+    out = []
+    graph = {}
+    for k in range(q):
+        u, v = data[1 + 2 * k], data[2 + 2 * k]
+        graph.setdefault(u, []).append(v)
+    for node in sorted(graph):
+        out.append("%s: %d" % (node, len(graph[node])))
+    print("\n".join(out))
+
+solve()
+`
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		bLanguage string
+		flagged   bool
+	}{
+		{"identical", sumSolution, sumSolution, "cpp", true},
+		{"renamed and reformatted", sumSolution, sumRenamed, "cpp", true},
+		{"padded copy", sumSolution, sumPadded, "cpp", true},
+		{"unrelated", sumSolution, sieveSolution, "cpp", false},
+		{"unrelated language", sumSolution, pythonSolution, "python3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Hashes(Fingerprint(Normalize("cpp", tt.a)))
+			b := Hashes(Fingerprint(Normalize(tt.bLanguage, tt.b)))
+			if len(a) < MinFingerprints || len(b) < MinFingerprints {
+				t.Fatalf("fingerprints of %d and %d hashes are too short to be checked", len(a), len(b))
+			}
+
+			score := Score(a, b)
+			if flagged := score >= DefaultThreshold; flagged != tt.flagged {
+				t.Errorf("score = %.2f, flagged = %v, want %v", score, flagged, tt.flagged)
+			}
+			if Score(b, a) != score {
+				t.Errorf("score is not symmetric: %.2f and %.2f", score, Score(b, a))
+			}
+		})
+	}
+}
+
+func TestEncodeHashes(t *testing.T) {
+	hashes := Hashes(Fingerprint(Normalize("cpp", sumSolution)))
+	if got := DecodeHashes(EncodeHashes(hashes)); !reflect.DeepEqual(got, hashes) {
+		t.Errorf("round trip = %v, want %v", got, hashes)
+	}
+	if got := DecodeHashes(fmt.Sprintf("%x zz %x", 10, 11)); !reflect.DeepEqual(got, []uint64{10, 11}) {
+		t.Errorf("malformed entries = %v, want them skipped", got)
+	}
+}